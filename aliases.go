@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gopasspw/gopass/pkg/debug"
+	"github.com/urfave/cli/v3"
+)
+
+// aliasIndexTTL is how long the aliases field of an indexed secret is trusted.
+// Aliases added by hand are picked up after at most this long.
+const aliasIndexTTL = time.Hour
+
+// canonical returns a copy of the credentials for the canonical host if the requested
+// host is configured as an alias of it, see credential-gopass.canonicalHost. The
+// canonical host replaces the host including the port. Otherwise cred is returned.
+func (s *gc) canonical(ctx context.Context, cred *gitCredentials) *gitCredentials {
+	host := s.config(ctx, configSection+".canonicalHost", cred)
+	if host == "" || strings.EqualFold(host, cred.Host) {
+		return cred
+	}
+
+	s.why("%s is an alias of %s", cred.Host, host)
+	c := *cred
+	c.Host = host
+
+	return &c
+}
+
+// searchAliases returns true if the aliases fields of the entries in the git tree
+// should be searched, see credential-gopass.searchAliases.
+func (s *gc) searchAliases(ctx context.Context, cred *gitCredentials) bool {
+	return strings.EqualFold(s.config(ctx, configSection+".searchAliases", cred), "true")
+}
+
+// aliasesMatch returns true if host is one of the comma or space separated aliases.
+func aliasesMatch(aliases, protocol, host string) bool {
+	host = normalizeHost(protocol, host)
+	for _, alias := range strings.FieldsFunc(aliases, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	}) {
+		if hostMatches(normalizeHost(protocol, alias), host) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// aliasCandidates returns the entries in the git tree that list the requested host
+// in their aliases field and match the requested username.
+func (s *gc) aliasCandidates(ctx context.Context, cred *gitCredentials, list func(prefix string) ([]string, error)) ([]*match, error) {
+	ls, err := list("")
+	if err != nil {
+		return nil, fmt.Errorf("error: %w while listing the storage", err)
+	}
+	names := make([]string, 0, len(ls))
+	for _, name := range ls {
+		if _, rest, ok := splitGitTree(name); ok && rest != "" {
+			names = append(names, name)
+		}
+	}
+
+	idx := s.updateFieldIndex(ctx, s.cachePath("alias-index.json"), aliasIndexTTL, names)
+
+	var candidates []*match
+	for _, name := range names {
+		e, found := idx.Entries[name]
+		if !found || !aliasesMatch(e.Aliases, cred.Protocol, cred.Host) {
+			continue
+		}
+
+		secret, err := s.gp.Get(ctx, name, "latest")
+		if err != nil {
+			debug.Log("failed to read %q: %s", name, err)
+
+			continue
+		}
+		m := &match{path: name, secret: secret}
+		if cred.Username != "" && secretUsername(m) != cred.Username {
+			s.why("%q: rejected, %s is an alias but the username %q does not match", name, cred.Host, secretUsername(m))
+
+			continue
+		}
+		candidates = append(candidates, m)
+	}
+
+	return candidates, nil
+}
+
+// findByAlias looks for an entry in the git tree that lists the requested host in its aliases field.
+func (s *gc) findByAlias(ctx context.Context, cred *gitCredentials, list func(prefix string) ([]string, error)) (*match, error) {
+	all, err := s.aliasCandidates(ctx, cred, list)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]*match, 0, len(all))
+	for _, m := range all {
+		if !s.usable(ctx, cred, m.path, m.secret) {
+			continue
+		}
+		s.why("%q: candidate with %s in its aliases", m.path, cred.Host)
+		candidates = append(candidates, m)
+	}
+
+	if len(candidates) < 1 {
+		return nil, nil
+	}
+
+	m := s.choose(ctx, cred, candidates)
+	if m == nil {
+		reportAmbiguous("with a matching alias", candidates)
+	}
+
+	return m, nil
+}
+
+// aliasPath returns the entry listing the requested host in its aliases field so
+// that store and erase change the canonical entry instead of creating a copy. Unlike
+// findByAlias it also considers expired and invalid entries. It returns an empty
+// string if there is no such entry or no rule decides between several.
+func (s *gc) aliasPath(ctx context.Context, cmd *cli.Command, cred *gitCredentials) string {
+	if !s.searchAliases(ctx, cred) {
+		return ""
+	}
+
+	candidates, err := s.aliasCandidates(ctx, cred, s.lister(ctx, cmd, cred))
+	if err != nil {
+		debug.Log("failed to look up aliases of %s: %s", cred.Host, err)
+
+		return ""
+	}
+	if len(candidates) < 1 {
+		return ""
+	}
+	if m := s.choose(ctx, cred, candidates); m != nil {
+		return m.path
+	}
+
+	return ""
+}
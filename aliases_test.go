@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gopasspw/gopass/pkg/ctxutil"
+	"github.com/gopasspw/gopass/pkg/gopass/apimock"
+	"github.com/gopasspw/gopass/pkg/gopass/secrets"
+	"github.com/gopasspw/gopass/pkg/termio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_aliasesMatch(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		aliases string
+		host    string
+		want    bool
+	}{
+		{"git.corp, api.git.corp", "git.corp", true},
+		{"git.corp, api.git.corp", "API.git.corp", true},
+		{"git.corp api.git.corp", "api.git.corp:8443", true},
+		{"git.corp:8443", "git.corp:8443", true},
+		{"git.corp:8443", "git.corp", false},
+		{"git.corp", "git.corp.example.com", false},
+		{"", "git.corp", false},
+	} {
+		assert.Equal(t, tc.want, aliasesMatch(tc.aliases, "https", tc.host), "%q %s", tc.aliases, tc.host)
+	}
+}
+
+func TestGitCredentialHelperAliases(t *testing.T) { //nolint:paralleltest
+	ctx := t.Context()
+	cfg := mapConfig{}
+	act := &gc{
+		gp:       apimock.New(),
+		cfg:      cfg,
+		cacheDir: t.TempDir(),
+	}
+
+	sec := secrets.New()
+	sec.SetPassword("t0ken")
+	require.NoError(t, sec.Set("login", "bob"))
+	require.NoError(t, sec.Set("aliases", "git.corp, api.git.corp:8443"))
+	require.NoError(t, act.gp.Set(ctx, "git/git.corp.example.com/bob", sec))
+
+	stdout := &bytes.Buffer{}
+	Stdout = stdout
+	defer func() {
+		Stdout = os.Stdout
+		termio.Stdin = os.Stdin
+	}()
+
+	ctx = ctxutil.WithStdin(ctx, true)
+	cmd := testCmd(t, ctx, nil)
+	get := func(host string) string {
+		t.Helper()
+
+		stdout.Reset()
+		termio.Stdin = strings.NewReader("protocol=https\nhost=" + host + "\nusername=bob\n")
+		require.NoError(t, act.Get(ctx, cmd))
+		read, err := parseGitCredentials(stdout)
+		require.NoError(t, err)
+
+		return read.Password
+	}
+	stored := func() string {
+		t.Helper()
+
+		sec, err := act.gp.Get(ctx, "git/git.corp.example.com/bob", "latest")
+		require.NoError(t, err)
+
+		return sec.Password()
+	}
+
+	// the aliases field is only searched if enabled
+	assert.Empty(t, get("git.corp"))
+
+	cfg["credential-gopass.searchAliases"] = "true"
+	assert.Equal(t, "t0ken", get("git.corp"))
+
+	// an alias is more specific than a wildcard
+	wild := secrets.New()
+	wild.SetPassword("wild")
+	require.NoError(t, act.gp.Set(ctx, "git/*.corp/bob", wild))
+	assert.Equal(t, "t0ken", get("git.corp"))
+	assert.Equal(t, "wild", get("other.corp"))
+	require.NoError(t, act.gp.Remove(ctx, "git/*.corp/bob"))
+	assert.Equal(t, "t0ken", get("api.git.corp:8443"))
+	assert.Empty(t, get("api.git.corp"))
+
+	// storing for an alias updates the canonical entry
+	termio.Stdin = strings.NewReader("protocol=https\nhost=git.corp\nusername=bob\npassword=n3w\n")
+	require.NoError(t, act.Store(ctx, cmd))
+	assert.Equal(t, "n3w", stored())
+	_, err := act.gp.Get(ctx, "git/git.corp/bob", "latest")
+	require.Error(t, err)
+	assert.Equal(t, "n3w", get("api.git.corp:8443"))
+
+	// the canonical host can be configured as well
+	delete(cfg, "credential-gopass.searchAliases")
+	cfg["credential-gopass.canonicalHost"] = "git.corp.example.com"
+	assert.Equal(t, "n3w", get("api.git.corp"))
+
+	termio.Stdin = strings.NewReader("protocol=https\nhost=api.git.corp:8443\nusername=bob\npassword=n3wer\n")
+	require.NoError(t, act.Store(ctx, cmd))
+	assert.Equal(t, "n3wer", stored())
+	_, err = act.gp.Get(ctx, "git/api.git.corp_8443/bob", "latest")
+	require.Error(t, err)
+
+	// the port of an alias is not recorded in the canonical entry
+	sec, err = act.gp.Get(ctx, "git/git.corp.example.com/bob", "latest")
+	require.NoError(t, err)
+	port, _ := sec.Get("port")
+	assert.Empty(t, port)
+}
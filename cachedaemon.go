@@ -0,0 +1,400 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gopasspw/gopass/pkg/debug"
+	"github.com/gopasspw/gopass/pkg/gopass"
+	"github.com/gopasspw/gopass/pkg/gopass/secrets"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	// defaultCacheTimeout is how long the cache daemon holds a credential by default.
+	defaultCacheTimeout = 15 * time.Minute
+	// cacheDialTimeout is how long a client waits for the cache daemon.
+	cacheDialTimeout = time.Second
+	// cacheSweepInterval is how often the cache daemon drops expired credentials.
+	cacheSweepInterval = time.Second
+)
+
+// cachedFields are the fields of a secret the cache daemon holds besides the password.
+var cachedFields = []string{"login", "authtype", "password_expiry_utc", "oauth_refresh_token"}
+
+// cacheRequest is sent by a client to the cache daemon.
+type cacheRequest struct {
+	Action string `json:"action"`
+	Key    string `json:"key,omitempty"`
+	Host   string `json:"host,omitempty"`
+	Path   string `json:"path,omitempty"`
+	Secret []byte `json:"secret,omitempty"`
+}
+
+// cacheResponse is the answer of the cache daemon. Secret is empty on a miss.
+type cacheResponse struct {
+	Secret []byte `json:"secret,omitempty"`
+}
+
+type cacheEntry struct {
+	host string
+	// path is the secret the credential was read from
+	path    string
+	secret  []byte
+	expires time.Time
+}
+
+// cacheDaemon holds decrypted credentials in memory for a limited time.
+type cacheDaemon struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	entries  map[string]*cacheEntry
+	lastUsed time.Time
+}
+
+func newCacheDaemon(ttl time.Duration) *cacheDaemon {
+	return &cacheDaemon{
+		ttl:      ttl,
+		entries:  map[string]*cacheEntry{},
+		lastUsed: time.Now(),
+	}
+}
+
+// handle answers a single request. It returns true if the daemon should exit.
+func (d *cacheDaemon) handle(req *cacheRequest, now time.Time) (*cacheResponse, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.lastUsed = now
+	switch req.Action {
+	case "get":
+		if e, found := d.entries[req.Key]; found && now.Before(e.expires) {
+			// the entry may expire while the answer is written
+			return &cacheResponse{Secret: slices.Clone(e.secret)}, false
+		}
+	case "store":
+		d.drop(req.Key)
+		d.entries[req.Key] = &cacheEntry{host: req.Host, path: req.Path, secret: req.Secret, expires: now.Add(d.ttl)}
+	case "erase":
+		for key, e := range d.entries {
+			if e.host == req.Host || (req.Path != "" && e.path == req.Path) {
+				d.drop(key)
+			}
+		}
+	case "exit":
+		for key := range d.entries {
+			d.drop(key)
+		}
+
+		return &cacheResponse{}, true
+	default:
+		debug.Log("unknown cache action %q", req.Action)
+	}
+
+	return &cacheResponse{}, false
+}
+
+// expire drops the expired credentials. It returns true once the cache was
+// empty and unused for a whole ttl.
+func (d *cacheDaemon) expire(now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for key, e := range d.entries {
+		if !now.Before(e.expires) {
+			d.drop(key)
+		}
+	}
+
+	return len(d.entries) == 0 && now.Sub(d.lastUsed) >= d.ttl
+}
+
+// drop removes an entry and overwrites the credential in memory. It must be called with mu held.
+func (d *cacheDaemon) drop(key string) {
+	e, found := d.entries[key]
+	if !found {
+		return
+	}
+	clear(e.secret)
+	delete(d.entries, key)
+}
+
+// serve answers requests until it is told to exit, the cache stayed empty
+// for a whole ttl or the context is canceled.
+func (d *cacheDaemon) serve(ctx context.Context, ln net.Listener) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		t := time.NewTicker(cacheSweepInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+			case now := <-t.C:
+				if !d.expire(now) {
+					continue
+				}
+				debug.Log("cache is empty, exiting")
+			}
+			cancel()
+			_ = ln.Close()
+
+			return
+		}
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				d.handle(&cacheRequest{Action: "exit"}, time.Now())
+
+				return nil
+			}
+
+			return err
+		}
+
+		if d.serveConn(conn) {
+			cancel()
+		}
+	}
+}
+
+func (d *cacheDaemon) serveConn(conn net.Conn) bool {
+	defer conn.Close() //nolint:errcheck
+
+	_ = conn.SetDeadline(time.Now().Add(5 * cacheDialTimeout))
+	req := &cacheRequest{}
+	if err := json.NewDecoder(conn).Decode(req); err != nil {
+		debug.Log("invalid cache request: %s", err)
+
+		return false
+	}
+
+	resp, exit := d.handle(req, time.Now())
+	if err := json.NewEncoder(conn).Encode(resp); err != nil {
+		debug.Log("failed to answer cache request: %s", err)
+	}
+	clear(resp.Secret)
+
+	return exit
+}
+
+// listenCache listens on the socket in a directory only accessible by the current user.
+// The mode of the directory is only fixed if it is dedicated to the daemon. Otherwise,
+// e.g. for a socket configured in the home directory, the daemon refuses to start if the
+// directory is accessible by other users.
+func listenCache(sock string, dedicated bool) (net.Listener, error) {
+	dir := filepath.Dir(sock)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	if dedicated {
+		if err := os.Chmod(dir, 0o700); err != nil {
+			return nil, err
+		}
+	} else {
+		fi, err := os.Stat(dir)
+		if err != nil {
+			return nil, err
+		}
+		if fi.Mode().Perm()&0o077 != 0 {
+			return nil, fmt.Errorf("refusing to listen on %s, %s is accessible by other users (mode %s)", sock, dir, fi.Mode().Perm())
+		}
+	}
+
+	if conn, err := net.DialTimeout("unix", sock, cacheDialTimeout); err == nil {
+		_ = conn.Close()
+
+		return nil, fmt.Errorf("cache daemon already running on %s", sock)
+	}
+	// remove a stale socket of a daemon that did not exit cleanly
+	if err := os.Remove(sock); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(sock, 0o600); err != nil {
+		_ = ln.Close()
+
+		return nil, err
+	}
+
+	return ln, nil
+}
+
+// cacheCall sends a request to the cache daemon.
+func cacheCall(sock string, req *cacheRequest) (*cacheResponse, error) {
+	conn, err := net.DialTimeout("unix", sock, cacheDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close() //nolint:errcheck
+
+	_ = conn.SetDeadline(time.Now().Add(5 * cacheDialTimeout))
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, err
+	}
+	resp := &cacheResponse{}
+	if err := json.NewDecoder(conn).Decode(resp); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// spawnCacheDaemon starts the cache daemon in the background. It is replaced in tests.
+var spawnCacheDaemon = func(sock string, ttl time.Duration) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(exe, "cache-daemon", "--socket="+sock, "--timeout="+ttl.String())
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	return cmd.Process.Release()
+}
+
+// cacheTimeout returns how long credentials are cached, see credential-gopass.cacheTimeout.
+// Zero disables the cache.
+func (s *gc) cacheTimeout(ctx context.Context, cred *gitCredentials) time.Duration {
+	return s.configDuration(ctx, configSection+".cacheTimeout", cred)
+}
+
+// cacheSocket returns the socket of the cache daemon of the current user.
+func (s *gc) cacheSocket(ctx context.Context, cred *gitCredentials) string {
+	if sock := s.config(ctx, configSection+".cacheSocket", cred); sock != "" {
+		return sock
+	}
+
+	return s.defaultCacheSocket()
+}
+
+// defaultCacheSocket returns the socket in the directory dedicated to the cache daemon.
+func (s *gc) defaultCacheSocket() string {
+	return s.cachePath(filepath.Join("daemon", "socket"))
+}
+
+// cacheKey identifies a credential request. It includes the options that change the lookup.
+func cacheKey(cmd *cli.Command, cred *gitCredentials) string {
+	cred = normalize(cred)
+
+	return strings.Join([]string{
+		cmd.String("store"),
+		cmd.String("path-template"),
+		strconv.FormatBool(cmd.Bool("search-urls")),
+		cred.Protocol,
+		cred.Host,
+		cred.Path,
+		cred.Username,
+	}, "\x00")
+}
+
+// cacheGet returns the cached secret for the request or nil.
+func (s *gc) cacheGet(ctx context.Context, cmd *cli.Command, cred *gitCredentials) gopass.Secret {
+	resp, err := cacheCall(s.cacheSocket(ctx, cred), &cacheRequest{Action: "get", Key: cacheKey(cmd, cred)})
+	if err != nil {
+		debug.Log("cache daemon not available: %s", err)
+
+		return nil
+	}
+	if len(resp.Secret) < 1 {
+		return nil
+	}
+
+	secret := secrets.ParseAKV(resp.Secret)
+	clear(resp.Secret)
+	if needsRefresh(secret, time.Now()) || isExpired(secret, time.Now()) {
+		// leave refreshing and warning to the regular lookup
+		return nil
+	}
+	debug.Log("using cached credential for %s", credentialURL(cred))
+
+	return secret
+}
+
+// cachePut hands the credential fields of the secret at path to the cache daemon and starts it if needed.
+func (s *gc) cachePut(ctx context.Context, cmd *cli.Command, cred *gitCredentials, path string, secret gopass.Secret, ttl time.Duration) {
+	cached := secrets.New()
+	cached.SetPassword(secret.Password())
+	for _, key := range cachedFields {
+		if v, _ := secret.Get(key); v != "" {
+			_ = cached.Set(key, v)
+		}
+	}
+	req := &cacheRequest{Action: "store", Key: cacheKey(cmd, cred), Host: normalizeHost(cred.Protocol, cred.Host), Path: path, Secret: cached.Bytes()}
+
+	sock := s.cacheSocket(ctx, cred)
+	if _, err := cacheCall(sock, req); err == nil {
+		return
+	}
+
+	if err := spawnCacheDaemon(sock, ttl); err != nil {
+		debug.Log("failed to start the cache daemon: %s", err)
+
+		return
+	}
+	for range 20 {
+		time.Sleep(50 * time.Millisecond)
+		if _, err := cacheCall(sock, req); err == nil {
+			return
+		}
+	}
+	debug.Log("cache daemon did not start on %s", sock)
+}
+
+// cacheInvalidate drops all cached credentials for the host of the request and those read
+// from the secret at path, e.g. for other hosts sharing it through an alias or a wildcard.
+func (s *gc) cacheInvalidate(ctx context.Context, cred *gitCredentials, path string) {
+	if s.cacheTimeout(ctx, cred) <= 0 {
+		return
+	}
+
+	if _, err := cacheCall(s.cacheSocket(ctx, cred), &cacheRequest{Action: "erase", Host: normalizeHost(cred.Protocol, cred.Host), Path: path}); err != nil {
+		debug.Log("cache daemon not available: %s", err)
+	}
+}
+
+// CacheDaemon runs the credential cache daemon in the foreground.
+func (s *gc) CacheDaemon(ctx context.Context, cmd *cli.Command) error {
+	sock := cmd.String("socket")
+	if sock == "" {
+		sock = s.cacheSocket(ctx, &gitCredentials{})
+	}
+
+	ln, err := listenCache(sock, sock == s.defaultCacheSocket())
+	if err != nil {
+		return err
+	}
+	defer os.Remove(sock) //nolint:errcheck
+
+	return newCacheDaemon(cmd.Duration("timeout")).serve(ctx, ln)
+}
+
+// CacheExit tells the cache daemon to forget all credentials and exit.
+func (s *gc) CacheExit(ctx context.Context, cmd *cli.Command) error {
+	if _, err := cacheCall(s.cacheSocket(ctx, &gitCredentials{}), &cacheRequest{Action: "exit"}); err != nil {
+		debug.Log("cache daemon not running: %s", err)
+	}
+
+	return nil
+}
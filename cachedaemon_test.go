@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gopasspw/gopass/pkg/ctxutil"
+	"github.com/gopasspw/gopass/pkg/gopass/apimock"
+	"github.com/gopasspw/gopass/pkg/gopass/secrets"
+	"github.com/gopasspw/gopass/pkg/termio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheDaemonExpire(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	d := newCacheDaemon(time.Minute)
+
+	secret := []byte("s3cret")
+	d.handle(&cacheRequest{Action: "store", Key: "k", Host: "example.com", Secret: secret}, now)
+	resp, _ := d.handle(&cacheRequest{Action: "get", Key: "k"}, now)
+	assert.Equal(t, []byte("s3cret"), resp.Secret)
+
+	// expired credentials are dropped and overwritten
+	assert.False(t, d.expire(now.Add(time.Minute-time.Second)))
+	assert.True(t, d.expire(now.Add(time.Minute)))
+	assert.Equal(t, make([]byte, len(secret)), secret)
+	resp, _ = d.handle(&cacheRequest{Action: "get", Key: "k"}, now)
+	assert.Empty(t, resp.Secret)
+
+	// erase drops every entry of the host
+	d.handle(&cacheRequest{Action: "store", Key: "a", Host: "example.com", Secret: []byte("a")}, now)
+	d.handle(&cacheRequest{Action: "store", Key: "b", Host: "example.org", Secret: []byte("b")}, now)
+	d.handle(&cacheRequest{Action: "erase", Host: "example.com"}, now)
+	assert.Len(t, d.entries, 1)
+
+	// and every entry read from the secret, whatever host it was cached for
+	d.handle(&cacheRequest{Action: "store", Key: "c", Host: "alias.example.org", Path: "git/example.org/bob", Secret: []byte("c")}, now)
+	d.handle(&cacheRequest{Action: "erase", Host: "example.net", Path: "git/example.org/bob"}, now)
+	assert.Len(t, d.entries, 1)
+
+	_, exit := d.handle(&cacheRequest{Action: "exit"}, now)
+	assert.True(t, exit)
+	assert.Empty(t, d.entries)
+}
+
+func TestGitCredentialHelperCache(t *testing.T) { //nolint:paralleltest
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	// unix socket paths are limited to about 100 characters
+	dir, err := os.MkdirTemp("", "gcg")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) //nolint:errcheck
+	sock := filepath.Join(dir, "daemon", "socket")
+
+	ln, err := listenCache(sock, false)
+	require.NoError(t, err)
+	fi, err := os.Stat(filepath.Dir(sock))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o700), fi.Mode().Perm())
+
+	// the mode of a directory not dedicated to the daemon is left alone
+	shared := filepath.Join(dir, "shared")
+	require.NoError(t, os.Mkdir(shared, 0o755))
+	require.NoError(t, os.Chmod(shared, 0o755))
+	_, err = listenCache(filepath.Join(shared, "socket"), false)
+	require.Error(t, err)
+	fi, err = os.Stat(shared)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o755), fi.Mode().Perm())
+
+	dedicated, err := listenCache(filepath.Join(shared, "daemon", "socket"), true)
+	require.NoError(t, err)
+	require.NoError(t, dedicated.Close())
+	fi, err = os.Stat(shared)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o755), fi.Mode().Perm())
+
+	done := make(chan error, 1)
+	go func() {
+		done <- newCacheDaemon(time.Minute).serve(ctx, ln)
+	}()
+
+	oldSpawn := spawnCacheDaemon
+	spawnCacheDaemon = func(string, time.Duration) error {
+		t.Error("cache daemon should be running")
+
+		return nil
+	}
+	defer func() {
+		spawnCacheDaemon = oldSpawn
+	}()
+
+	act := &gc{
+		gp: apimock.New(),
+		cfg: mapConfig{
+			"credential-gopass.cacheTimeout":  "1m",
+			"credential-gopass.cacheSocket":   sock,
+			"credential-gopass.searchAliases": "true",
+		},
+		cacheDir: t.TempDir(),
+	}
+	sec := secrets.New()
+	sec.SetPassword("s3cret")
+	require.NoError(t, sec.Set("login", "alice"))
+	require.NoError(t, sec.Set("comment", "not cached"))
+	require.NoError(t, act.gp.Set(ctx, "git/example.com/bob", sec))
+
+	stdout := &bytes.Buffer{}
+	Stdout = stdout
+	defer func() {
+		Stdout = os.Stdout
+		termio.Stdin = os.Stdin
+	}()
+
+	ctx = ctxutil.WithStdin(ctx, true)
+	cmd := testCmd(t, ctx, nil)
+	s := "protocol=https\nhost=example.com\nusername=bob\n"
+	get := func() *gitCredentials {
+		t.Helper()
+
+		stdout.Reset()
+		termio.Stdin = strings.NewReader(s)
+		require.NoError(t, act.Get(ctx, cmd))
+		read, err := parseGitCredentials(stdout)
+		require.NoError(t, err)
+
+		return read
+	}
+
+	assert.Equal(t, "s3cret", get().Password)
+
+	// the second get is answered from the cache
+	gp := act.gp
+	act.gp = apimock.New()
+	read := get()
+	assert.Equal(t, "s3cret", read.Password)
+	assert.Equal(t, "alice", read.Username)
+
+	resp, err := cacheCall(sock, &cacheRequest{Action: "get", Key: cacheKey(cmd, &gitCredentials{Protocol: "https", Host: "example.com", Username: "bob"})})
+	require.NoError(t, err)
+	assert.NotContains(t, string(resp.Secret), "not cached")
+
+	// store and erase invalidate the cache
+	termio.Stdin = strings.NewReader(s + "password=n3w\n")
+	require.NoError(t, act.Store(ctx, cmd))
+	assert.Equal(t, "n3w", get().Password)
+
+	act.gp = gp
+	termio.Stdin = strings.NewReader(s + "password=s3cret\n")
+	require.NoError(t, act.Erase(ctx, cmd))
+	assert.Empty(t, get().Password)
+
+	// a credential stored for an alias invalidates the canonical host, too
+	sec = secrets.New()
+	sec.SetPassword("t0ken")
+	require.NoError(t, sec.Set("aliases", "api.git.corp"))
+	require.NoError(t, act.gp.Set(ctx, "git/git.corp/bob", sec))
+	s = "protocol=https\nhost=git.corp\nusername=bob\n"
+	assert.Equal(t, "t0ken", get().Password)
+	s = "protocol=https\nhost=api.git.corp\nusername=bob\n"
+	assert.Equal(t, "t0ken", get().Password)
+
+	termio.Stdin = strings.NewReader(s + "password=new\n")
+	require.NoError(t, act.Store(ctx, cmd))
+	s = "protocol=https\nhost=git.corp\nusername=bob\n"
+	assert.Equal(t, "new", get().Password)
+
+	require.NoError(t, act.CacheExit(ctx, cmd))
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("cache daemon did not exit")
+	}
+}
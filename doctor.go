@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/gopasspw/gopass/pkg/ctxutil"
+	"github.com/gopasspw/gopass/pkg/gopass"
+	"github.com/urfave/cli/v3"
+)
+
+// configScopes are the git config scopes in the order git reads them.
+var configScopes = []string{"system", "global", "local"}
+
+const (
+	checkOK   = "OK"
+	checkWarn = "WARN"
+	checkFail = "FAIL"
+)
+
+// check is the result of a single doctor check.
+type check struct {
+	status string
+	msg    string
+	fix    string
+}
+
+// gitConfigAll returns all values of key in the given scope. A scope that is
+// not available, e.g. local outside of a repository, has no values.
+func gitConfigAll(ctx context.Context, scope, key string) []string {
+	out, err := exec.CommandContext(ctx, "git", "config", "--"+scope, "--get-all", key).Output()
+	if err != nil {
+		return nil
+	}
+
+	return strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+}
+
+// isGopassHelper returns true if the credential.helper value refers to this helper.
+func isGopassHelper(helper string) bool {
+	fields := strings.Fields(helper)
+	if len(fields) < 1 {
+		return false
+	}
+
+	return fields[0] == "gopass" || strings.TrimSuffix(filepath.Base(fields[0]), ".exe") == "git-credential-gopass"
+}
+
+// helperChain returns the helpers git calls in order. An empty value resets the list.
+func helperChain(values []string) []string {
+	var chain []string
+	for _, v := range values {
+		if v == "" {
+			chain = nil
+
+			continue
+		}
+		chain = append(chain, v)
+	}
+
+	return chain
+}
+
+// helperStores returns the value of the --store option of a credential.helper value.
+func helperStores(helper string) string {
+	for _, field := range strings.Fields(helper) {
+		if v, found := strings.CutPrefix(field, "--store="); found {
+			return strings.Trim(v, `'"`)
+		}
+	}
+
+	return ""
+}
+
+// Doctor checks the setup of the helper end to end and suggests fixes.
+func (s *gc) Doctor(ctx context.Context, cmd *cli.Command) error {
+	var checks []check
+
+	// which helpers does git call?
+	var values []string
+	for _, scope := range configScopes {
+		vs := gitConfigAll(ctx, scope, "credential.helper")
+		for _, v := range vs {
+			checks = append(checks, check{status: checkOK, msg: fmt.Sprintf("credential.helper (%s): %q", scope, v)})
+		}
+		values = append(values, vs...)
+	}
+
+	chain := helperChain(values)
+	gopassHelper := ""
+	for i, helper := range chain {
+		if !isGopassHelper(helper) {
+			continue
+		}
+		gopassHelper = helper
+		if i > 0 {
+			checks = append(checks, check{
+				status: checkWarn,
+				msg:    fmt.Sprintf("git asks %q before gopass, it may answer first", strings.Join(chain[:i], ", ")),
+				fix:    "only ask gopass: git config --global --replace-all credential.helper " + shellQuote(helper),
+			})
+		}
+
+		break
+	}
+	if gopassHelper == "" {
+		checks = append(checks, check{
+			status: checkFail,
+			msg:    "gopass is not configured as a credential.helper, git never calls it",
+			fix:    "git-credential-gopass configure --global",
+		})
+	}
+
+	// does git send the repository path?
+	useHTTPPath := "false"
+	for _, scope := range configScopes {
+		if vs := gitConfigAll(ctx, scope, "credential.useHttpPath"); len(vs) > 0 {
+			useHTTPPath = vs[len(vs)-1]
+			checks = append(checks, check{status: checkOK, msg: fmt.Sprintf("credential.useHttpPath (%s): %s", scope, useHTTPPath)})
+		}
+	}
+	if useHTTPPath != "true" {
+		checks = append(checks, check{
+			status: checkOK,
+			msg: "credential.useHttpPath is not enabled, credentials are looked up per host. " +
+				"Run git config --global credential.useHttpPath true to use different credentials per repository",
+		})
+	}
+
+	// can gopass be used without a terminal?
+	gp, err := newAPI(ctxutil.WithInteractive(ctx, false))
+	if err != nil {
+		checks = append(checks, check{
+			status: checkFail,
+			msg:    fmt.Sprintf("gopass API can not be initialized non-interactively: %s", err),
+			fix:    "run gopass setup or make sure gopass works in a non-interactive shell",
+		})
+		printChecks(checks)
+
+		return fmt.Errorf("found %d problem(s)", countProblems(checks))
+	}
+	defer gp.Close(ctx) //nolint:errcheck
+	checks = append(checks, check{status: checkOK, msg: "gopass API initialized non-interactively"})
+
+	ls, err := gp.List(ctx)
+	if err != nil {
+		checks = append(checks, check{status: checkFail, msg: fmt.Sprintf("failed to list the store: %s", err), fix: "run gopass fsck"})
+	}
+
+	// do the configured stores exist?
+	stores := cmd.String("store")
+	if stores == "" {
+		stores = helperStores(gopassHelper)
+	}
+	for _, store := range parseStores(stores) {
+		if store == "" {
+			continue
+		}
+		if hasPrefix(ls, store+"/") {
+			checks = append(checks, check{status: checkOK, msg: fmt.Sprintf("store %q exists", store)})
+
+			continue
+		}
+		checks = append(checks, check{
+			status: checkFail,
+			msg:    fmt.Sprintf("store %q is not mounted or empty", store),
+			fix:    fmt.Sprintf("gopass mounts add %s <path> or gopass clone <url> %s", store, store),
+		})
+	}
+
+	// can secrets be decrypted without a prompt?
+	if c, ok := agentCheck(ctx); ok {
+		checks = append(checks, c)
+	}
+	checks = append(checks, decryptCheck(ctx, gp, ls))
+
+	printChecks(checks)
+	if n := countProblems(checks); n > 0 {
+		return fmt.Errorf("found %d problem(s)", n)
+	}
+
+	return nil
+}
+
+// gpgAgentKeyInfo lists the keys known to a running gpg-agent. It never starts the
+// agent and never asks for a passphrase. It is replaced in tests.
+var gpgAgentKeyInfo = func(ctx context.Context) ([]byte, error) {
+	return exec.CommandContext(ctx, "gpg-connect-agent", "--no-autostart", "KEYINFO --list", "/bye").CombinedOutput()
+}
+
+// cachedKeys counts the keys with a cached passphrase in the output of KEYINFO --list,
+// e.g. "S KEYINFO <keygrip> D - - 1 P - - -" where the 1 marks a cached passphrase.
+func cachedKeys(out []byte) int {
+	n := 0
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 6 && fields[0] == "S" && fields[1] == "KEYINFO" && fields[6] == "1" {
+			n++
+		}
+	}
+
+	return n
+}
+
+// agentCheck asks gpg-agent whether it has a passphrase cached. Decrypting a gpg secret
+// can open pinentry even if gopass runs non-interactively, so this is the only way to
+// tell whether git would get a credential without a prompt. It returns false if gpg is
+// not installed, e.g. for stores using age.
+func agentCheck(ctx context.Context) (check, bool) {
+	if _, err := exec.LookPath("gpg-connect-agent"); err != nil {
+		return check{}, false
+	}
+
+	out, err := gpgAgentKeyInfo(ctx)
+	if err != nil || strings.Contains(string(out), "no gpg-agent running") {
+		return check{
+			status: checkWarn,
+			msg:    "gpg-agent is not running, decrypting a credential for git may prompt for the passphrase",
+			fix:    "start it with gpg-connect-agent /bye and set default-cache-ttl in gpg-agent.conf",
+		}, true
+	}
+	if cachedKeys(out) < 1 {
+		return check{
+			status: checkWarn,
+			msg:    "gpg-agent has no passphrase cached, decrypting a credential for git may prompt for it (ignore this if your stores use age)",
+			fix:    "unlock the key once, e.g. with gopass show, and raise default-cache-ttl in gpg-agent.conf",
+		}, true
+	}
+
+	return check{status: checkOK, msg: "gpg-agent has a passphrase cached"}, true
+}
+
+// decryptCheck decrypts one of the credentials in a git tree. gopass does not prevent
+// gpg-agent from asking for the passphrase, so this only shows that decryption works,
+// see agentCheck.
+func decryptCheck(ctx context.Context, gp gopass.Store, ls []string) check {
+	for _, name := range ls {
+		if _, _, ok := splitGitTree(name); !ok {
+			continue
+		}
+
+		if _, err := gp.Get(ctxutil.WithInteractive(ctx, false), name, "latest"); err != nil {
+			return check{
+				status: checkFail,
+				msg:    fmt.Sprintf("failed to decrypt %q: %s", name, err),
+				fix:    "make sure gpg-agent caches your passphrase (default-cache-ttl) or unlock the age identities with the gopass agent",
+			}
+		}
+
+		return check{status: checkOK, msg: fmt.Sprintf("decryption works, decrypted %q", name)}
+	}
+
+	return check{
+		status: checkWarn,
+		msg:    "no credentials in a git/ tree to test decryption with",
+		fix:    "store a credential, e.g. by running git fetch against a remote that needs authentication",
+	}
+}
+
+func hasPrefix(ls []string, prefix string) bool {
+	for _, e := range ls {
+		if strings.HasPrefix(e, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func countProblems(checks []check) int {
+	n := 0
+	for _, c := range checks {
+		if c.status == checkFail {
+			n++
+		}
+	}
+
+	return n
+}
+
+func printChecks(checks []check) {
+	for _, c := range checks {
+		fmt.Fprintf(Stdout, "[%s] %s\n", c.status, c.msg)
+		if c.fix != "" {
+			fmt.Fprintf(Stdout, "       fix: %s\n", c.fix)
+		}
+	}
+	if countProblems(checks) == 0 {
+		fmt.Fprintln(Stdout, "No problems found.")
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/gopasspw/gopass/pkg/gopass"
+	"github.com/gopasspw/gopass/pkg/gopass/apimock"
+	"github.com/gopasspw/gopass/pkg/gopass/secrets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+func Test_helperChain(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, helperChain(nil))
+	assert.Equal(t, []string{"cache", "gopass"}, helperChain([]string{"cache", "gopass"}))
+	assert.Equal(t, []string{"gopass --store=work"}, helperChain([]string{"cache", "", "gopass --store=work"}))
+}
+
+func Test_isGopassHelper(t *testing.T) {
+	t.Parallel()
+
+	for helper, want := range map[string]bool{
+		"gopass":                               true,
+		"gopass --store=work":                  true,
+		"/usr/local/bin/git-credential-gopass": true,
+		"cache --timeout=300":                  false,
+		"":                                     false,
+	} {
+		assert.Equal(t, want, isGopassHelper(helper), helper)
+	}
+}
+
+func Test_helperStores(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "", helperStores("gopass"))
+	assert.Equal(t, "work,/", helperStores("gopass --store=work,/ --search-urls"))
+	assert.Equal(t, "work", helperStores("gopass --store='work'"))
+}
+
+func Test_cachedKeys(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, 0, cachedKeys(nil))
+	assert.Equal(t, 0, cachedKeys([]byte("S KEYINFO 0123 D - - - P - - -\nOK\n")))
+	assert.Equal(t, 1, cachedKeys([]byte("S KEYINFO 0123 D - - - P - - -\nS KEYINFO 4567 D - - 1 P - - -\nOK\n")))
+}
+
+func TestDoctor(t *testing.T) { //nolint:paralleltest
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+
+	ctx := t.Context()
+	td := t.TempDir()
+	t.Chdir(td)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_CONFIG_GLOBAL", filepath.Join(td, "gitconfig"))
+	require.NoError(t, exec.Command("git", "config", "--global", "credential.helper", "cache").Run())
+	require.NoError(t, exec.Command("git", "config", "--global", "--add", "credential.helper", "gopass --store=work").Run())
+
+	gp := apimock.New()
+	sec := secrets.New()
+	sec.SetPassword("s3cret")
+	require.NoError(t, gp.Set(ctx, "work/git/github.com/bob", sec))
+
+	oldAPI := newAPI
+	newAPI = func(context.Context) (gopass.Store, error) {
+		return gp, nil
+	}
+	oldKeyInfo := gpgAgentKeyInfo
+	gpgAgentKeyInfo = func(context.Context) ([]byte, error) {
+		return []byte("S KEYINFO 0123 D - - 1 P - - -\nOK\n"), nil
+	}
+	defer func() {
+		newAPI = oldAPI
+		gpgAgentKeyInfo = oldKeyInfo
+	}()
+
+	stdout := &bytes.Buffer{}
+	Stdout = stdout
+	defer func() {
+		Stdout = os.Stdout
+	}()
+
+	run := func(args ...string) error {
+		t.Helper()
+
+		cmd := &cli.Command{
+			Name:   "doctor",
+			Flags:  []cli.Flag{&cli.StringFlag{Name: "store"}},
+			Action: (&gc{}).Doctor,
+		}
+		stdout.Reset()
+
+		return cmd.Run(ctx, append([]string{"doctor"}, args...))
+	}
+
+	require.NoError(t, run())
+	assert.Contains(t, stdout.String(), `[OK] credential.helper (global): "gopass --store=work"`)
+	assert.Contains(t, stdout.String(), `[WARN] git asks "cache" before gopass`)
+	assert.Contains(t, stdout.String(), `[OK] store "work" exists`)
+	assert.Contains(t, stdout.String(), `[OK] decryption works, decrypted "work/git/github.com/bob"`)
+	if _, err := exec.LookPath("gpg-connect-agent"); err == nil {
+		assert.Contains(t, stdout.String(), "[OK] gpg-agent has a passphrase cached")
+	}
+	assert.NotContains(t, stdout.String(), "s3cret")
+
+	require.Error(t, run("--store=missing"))
+	assert.Contains(t, stdout.String(), `[FAIL] store "missing" is not mounted or empty`)
+	assert.Contains(t, stdout.String(), "fix: gopass mounts add missing")
+
+	require.NoError(t, exec.Command("git", "config", "--global", "--replace-all", "credential.helper", "cache").Run())
+	require.Error(t, run())
+	assert.Contains(t, stdout.String(), "[FAIL] gopass is not configured as a credential.helper")
+	assert.Contains(t, stdout.String(), "fix: git-credential-gopass configure --global")
+}
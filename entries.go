@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/gopasspw/gopass/pkg/debug"
+	"github.com/gopasspw/gopass/pkg/gopass"
+)
+
+// gitTree is the directory below a store mount holding the credentials.
+const gitTree = "git/"
+
+// hostPortRe matches a host with a port in a secret name, e.g. example.com_8080.
+var hostPortRe = regexp.MustCompile(`^(.+)_(\d+)$`)
+
+// entry is a credential stored in the git tree of a store.
+type entry struct {
+	Store  string
+	Name   string
+	Cred   *gitCredentials
+	Secret gopass.Secret
+}
+
+// URL rebuilds the URL of the entry without the password.
+func (e *entry) URL() string {
+	return credentialString(e.Cred)
+}
+
+// splitGitTree splits a secret name into the store mount and the part below the git tree.
+// It returns false if the secret is not in a git tree.
+func splitGitTree(name string) (string, string, bool) {
+	if strings.HasPrefix(name, gitTree) {
+		return "", strings.TrimPrefix(name, gitTree), true
+	}
+
+	store, rest, found := strings.Cut(name, "/"+gitTree)
+	if !found {
+		return "", "", false
+	}
+
+	return store, rest, true
+}
+
+// parseEntry rebuilds the credentials from the secret name in the classic
+// git/<host>[_<port>][/<path>]/<username> layout and the secret fields.
+// The url, protocol and login fields take precedence over the name.
+func parseEntry(rest string, secret gopass.Secret) *gitCredentials {
+	cred := parseName(rest)
+
+	if v, _ := secret.Get("url"); v != "" {
+		if u, err := url.Parse(v); err == nil && u.Host != "" {
+			cred.Protocol = u.Scheme
+			cred.Host = u.Host
+			cred.Path = strings.Trim(u.Path, "/")
+		}
+	}
+	if v, _ := secret.Get("protocol"); v != "" {
+		cred.Protocol = v
+	}
+	if v, _ := secret.Get("port"); v != "" && !isWildcard(cred.Host) {
+		if _, port := splitHostPort(cred.Host); port == "" {
+			cred.Host = net.JoinHostPort(cred.Host, v)
+		}
+	}
+	if v, _ := secret.Get("login"); v != "" {
+		cred.Username = v
+	}
+	cred.Password = secret.Password()
+	cred.PasswordExpiryUTC, _ = secret.Get("password_expiry_utc")
+
+	return cred
+}
+
+// parseName rebuilds the credentials from the secret name in the classic
+// git/<host>[_<port>][/<path>]/<username> layout. The components are decoded,
+// see encodeName. Names in the lossy layout of earlier versions are taken as is.
+func parseName(rest string) *gitCredentials {
+	cred := &gitCredentials{Protocol: "https"}
+
+	parts := strings.Split(rest, "/")
+	cred.Host = decoded(parts[0], false)
+	if m := hostPortRe.FindStringSubmatch(parts[0]); m != nil {
+		cred.Host = net.JoinHostPort(decoded(m[1], false), m[2])
+	}
+	if len(parts) > 1 {
+		cred.Username = decoded(parts[len(parts)-1], false)
+	}
+	if len(parts) > 2 {
+		path := make([]string, 0, len(parts)-2)
+		for _, p := range parts[1 : len(parts)-1] {
+			path = append(path, decoded(p, true))
+		}
+		cred.Path = strings.Join(path, "/")
+	}
+
+	return cred
+}
+
+// decoded decodes a path component or returns it unchanged if it is not encoded.
+func decoded(s string, path bool) string {
+	v, err := decodeComponent(s, path)
+	if err != nil {
+		return s
+	}
+
+	return v
+}
+
+// entries returns all credentials in the git trees of the given stores.
+// All stores are considered if the list is empty.
+func (s *gc) entries(ctx context.Context, stores []string) ([]*entry, error) {
+	ls, err := s.gp.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error: %w while listing the storage", err)
+	}
+
+	out := make([]*entry, 0, len(ls))
+	for _, name := range ls {
+		store, rest, ok := splitGitTree(name)
+		if !ok || rest == "" {
+			continue
+		}
+		if len(stores) > 0 && !slices.Contains(stores, store) {
+			continue
+		}
+
+		secret, err := s.gp.Get(ctx, name, "latest")
+		if err != nil {
+			debug.Log("failed to read %q: %s", name, err)
+
+			continue
+		}
+
+		out = append(out, &entry{
+			Store:  store,
+			Name:   name,
+			Cred:   parseEntry(rest, secret),
+			Secret: secret,
+		})
+	}
+
+	return out, nil
+}
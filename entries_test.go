@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gopasspw/gopass/pkg/gopass/secrets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_splitGitTree(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name  string
+		store string
+		rest  string
+		ok    bool
+	}{
+		{name: "git/github.com/bob", rest: "github.com/bob", ok: true},
+		{name: "work/git/github.com/bob", store: "work", rest: "github.com/bob", ok: true},
+		{name: "websites/github.com/bob"},
+		{name: "gitlab/foo"},
+	} {
+		store, rest, ok := splitGitTree(tc.name)
+		assert.Equal(t, tc.ok, ok, tc.name)
+		assert.Equal(t, tc.store, store, tc.name)
+		assert.Equal(t, tc.rest, rest, tc.name)
+	}
+}
+
+func Test_parseEntry(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		rest string
+		kvs  map[string]string
+		want *gitCredentials
+	}{
+		{
+			rest: "github.com/bob",
+			want: &gitCredentials{Protocol: "https", Host: "github.com", Username: "bob", Password: "pw"},
+		},
+		{
+			rest: "localhost_8080",
+			kvs:  map[string]string{"login": "alice", "protocol": "http"},
+			want: &gitCredentials{Protocol: "http", Host: "localhost:8080", Username: "alice", Password: "pw"},
+		},
+		{
+			rest: "github.com/repo1/bob",
+			kvs:  map[string]string{"password_expiry_utc": "2000"},
+			want: &gitCredentials{Protocol: "https", Host: "github.com", Path: "repo1", Username: "bob", Password: "pw", PasswordExpiryUTC: "2000"},
+		},
+		{
+			rest: "github.com/org_repo/bob",
+			kvs:  map[string]string{"url": "https://github.com/org/repo"},
+			want: &gitCredentials{Protocol: "https", Host: "github.com", Path: "org/repo", Username: "bob", Password: "pw"},
+		},
+	} {
+		sec := secrets.New()
+		sec.SetPassword("pw")
+		for k, v := range tc.kvs {
+			require.NoError(t, sec.Set(k, v))
+		}
+		assert.Equal(t, tc.want, parseEntry(tc.rest, sec), tc.rest)
+	}
+}
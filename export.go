@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+)
+
+// exportedCredential is the JSON representation of an exported credential.
+type exportedCredential struct {
+	URL               string `json:"url"`
+	Protocol          string `json:"protocol"`
+	Host              string `json:"host"`
+	Path              string `json:"path,omitempty"`
+	Username          string `json:"username,omitempty"`
+	Password          string `json:"password"`
+	PasswordExpiryUTC string `json:"password_expiry_utc,omitempty"`
+	Store             string `json:"store,omitempty"`
+	Secret            string `json:"secret"`
+}
+
+// filterEntries returns the entries of the given stores matching the host filter.
+func (s *gc) filterEntries(ctx context.Context, cmd *cli.Command) ([]*entry, error) {
+	var stores []string
+	if cmd.IsSet("store") {
+		stores = parseStores(cmd.String("store"))
+	}
+
+	all, err := s.entries(ctx, stores)
+	if err != nil {
+		return nil, err
+	}
+
+	host := cmd.String("host")
+	if host == "" {
+		return all, nil
+	}
+
+	out := all[:0]
+	for _, e := range all {
+		if _, ok := wildcardScore(e.Cred.Host, host); ok || hostMatches(host, e.Cred.Host) {
+			out = append(out, e)
+		}
+	}
+
+	return out, nil
+}
+
+// Export writes the credentials of the git tree in the git-credential-store, netrc or JSON format.
+func (s *gc) Export(ctx context.Context, cmd *cli.Command) error {
+	var write func(io.Writer, []*entry) error
+	switch format := cmd.String("format"); format {
+	case "git-credentials":
+		write = exportCredentialStore
+	case "netrc":
+		write = exportNetrc
+	case "json":
+		write = exportJSON
+	default:
+		return fmt.Errorf("unknown format %q, use git-credentials, netrc or json", format)
+	}
+
+	entries, err := s.filterEntries(ctx, cmd)
+	if err != nil {
+		return err
+	}
+
+	if fn := cmd.String("output"); fn != "" && fn != "-" {
+		return writeFileAtomic(fn, func(w io.Writer) error {
+			return write(w, entries)
+		})
+	}
+
+	if err := write(Stdout, entries); err != nil {
+		return fmt.Errorf("failed to write credentials: %w", err)
+	}
+
+	return nil
+}
+
+// writeFileAtomic writes a file readable by the current user only. The content is
+// written to a temporary file next to it first, so the existing file is only replaced
+// once everything was written.
+func writeFileAtomic(fn string, write func(io.Writer) error) error {
+	fh, err := os.CreateTemp(filepath.Dir(fn), "."+filepath.Base(fn)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create a temporary file for %s: %w", fn, err)
+	}
+	tmp := fh.Name()
+	defer os.Remove(tmp) //nolint:errcheck
+
+	if err := write(fh); err != nil {
+		_ = fh.Close()
+
+		return fmt.Errorf("failed to write credentials: %w", err)
+	}
+	if err := fh.Sync(); err != nil {
+		_ = fh.Close()
+
+		return fmt.Errorf("failed to write credentials: %w", err)
+	}
+	if err := fh.Close(); err != nil {
+		return fmt.Errorf("failed to write credentials: %w", err)
+	}
+
+	if err := os.Rename(tmp, fn); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", fn, err)
+	}
+
+	return nil
+}
+
+// exportCredentialStore writes the file format of git-credential-store.
+func exportCredentialStore(w io.Writer, entries []*entry) error {
+	for _, e := range entries {
+		if isWildcard(e.Cred.Host) {
+			fmt.Fprintf(os.Stderr, "gopass warning: skipping %s, git-credential-store does not support wildcard hosts\n", e.Name)
+
+			continue
+		}
+		u := &url.URL{
+			Scheme: e.Cred.Protocol,
+			Host:   e.Cred.Host,
+			User:   url.UserPassword(e.Cred.Username, e.Cred.Password),
+		}
+		if e.Cred.Path != "" {
+			u.Path = "/" + e.Cred.Path
+		}
+		if _, err := fmt.Fprintln(w, u.String()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// exportNetrc writes a netrc file. netrc has no notion of protocols, ports and
+// paths, so only the first entry of each host is written.
+func exportNetrc(w io.Writer, entries []*entry) error {
+	seen := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		host, _ := splitHostPort(e.Cred.Host)
+		if seen[host] {
+			continue
+		}
+		if isWildcard(host) {
+			fmt.Fprintf(os.Stderr, "gopass warning: skipping %s, netrc does not support wildcard hosts\n", e.Name)
+
+			continue
+		}
+		if strings.ContainsAny(e.Cred.Username+e.Cred.Password, " \t\n") {
+			fmt.Fprintf(os.Stderr, "gopass warning: skipping %s, netrc does not support whitespace in credentials\n", e.Name)
+
+			continue
+		}
+		seen[host] = true
+
+		line := "machine " + host
+		if e.Cred.Username != "" {
+			line += " login " + e.Cred.Username
+		}
+		line += " password " + e.Cred.Password
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// exportJSON writes a JSON array of the credentials.
+func exportJSON(w io.Writer, entries []*entry) error {
+	out := make([]exportedCredential, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, exportedCredential{
+			URL:               e.URL(),
+			Protocol:          e.Cred.Protocol,
+			Host:              e.Cred.Host,
+			Path:              e.Cred.Path,
+			Username:          e.Cred.Username,
+			Password:          e.Cred.Password,
+			PasswordExpiryUTC: e.Cred.PasswordExpiryUTC,
+			Store:             e.Store,
+			Secret:            e.Name,
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(out)
+}
@@ -9,12 +9,14 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/gopasspw/gopass/pkg/ctxutil"
 	"github.com/gopasspw/gopass/pkg/debug"
-	"github.com/gopasspw/gopass/pkg/fsutil"
 	"github.com/gopasspw/gopass/pkg/gopass"
+	"github.com/gopasspw/gopass/pkg/gopass/api"
 	"github.com/gopasspw/gopass/pkg/gopass/secrets"
 	"github.com/gopasspw/gopass/pkg/termio"
 	"github.com/urfave/cli/v3"
@@ -23,7 +25,28 @@ import (
 // Stdout is exported for tests.
 var Stdout io.Writer = os.Stdout
 
+// capabilityAuthType is the capability git (2.46+) advertises when it can handle
+// pre-encoded Authorization values (authtype and credential) instead of a username/password pair.
+const capabilityAuthType = "authtype"
+
+// capabilityState is the capability git advertises when it passes state[] attributes
+// back to the helper, e.g. during multi-stage authentication.
+const capabilityState = "state"
+
+// supportedCapabilities lists the capabilities this helper understands.
+var supportedCapabilities = []string{capabilityAuthType, capabilityState}
+
+// gitAttribute is a single key=value line of the git-credential format.
+// Array attributes like wwwauth[] keep their brackets in the key.
+type gitAttribute struct {
+	Key   string
+	Value string
+}
+
 type gitCredentials struct {
+	Capabilities      []string
+	AuthType          string
+	Credential        string
 	Protocol          string
 	Host              string
 	Path              string
@@ -31,12 +54,94 @@ type gitCredentials struct {
 	Password          string
 	PasswordExpiryUTC string
 	OAuthRefreshToken string
+	// Attributes holds every other attribute git sent, in order. Keys may occur
+	// more than once, e.g. wwwauth[] or state[].
+	Attributes []gitAttribute
+}
+
+// Values returns all values of the given (array) attribute in the order they were sent.
+func (c *gitCredentials) Values(key string) []string {
+	var out []string
+	for _, a := range c.Attributes {
+		if a.Key == key {
+			out = append(out, a.Value)
+		}
+	}
+
+	return out
+}
+
+// Add appends a value to the given attribute.
+func (c *gitCredentials) Add(key, value string) {
+	c.Attributes = append(c.Attributes, gitAttribute{Key: key, Value: value})
+}
+
+// Del removes all values of the given attribute.
+func (c *gitCredentials) Del(key string) {
+	c.Attributes = slices.DeleteFunc(c.Attributes, func(a gitAttribute) bool {
+		return a.Key == key
+	})
+}
+
+// AuthSchemes returns the lowercased authentication schemes of all
+// WWW-Authenticate challenges git received from the server.
+func (c *gitCredentials) AuthSchemes() []string {
+	var schemes []string
+	for _, challenge := range c.Values("wwwauth[]") {
+		scheme, _, _ := strings.Cut(strings.TrimSpace(challenge), " ")
+		if scheme == "" {
+			continue
+		}
+		schemes = append(schemes, strings.ToLower(scheme))
+	}
+
+	return schemes
+}
+
+// HasCapability returns true if the given capability was announced.
+func (c *gitCredentials) HasCapability(capability string) bool {
+	return slices.Contains(c.Capabilities, capability)
+}
+
+// negotiateCapabilities restricts the announced capabilities to the ones this helper supports.
+func (c *gitCredentials) negotiateCapabilities() {
+	caps := make([]string, 0, len(c.Capabilities))
+	for _, capability := range c.Capabilities {
+		if slices.Contains(supportedCapabilities, capability) {
+			caps = append(caps, capability)
+		}
+	}
+	c.Capabilities = caps
 }
 
 // WriteTo writes the given credentials to the given io.Writer in the git-credential format.
 func (c *gitCredentials) WriteTo(w io.Writer) (int64, error) {
 	var n int64
 
+	for _, capability := range c.Capabilities {
+		i, err := io.WriteString(w, "capability[]="+capability+"\n")
+		n += int64(i)
+		if err != nil {
+			return n, err
+		}
+	}
+
+	if c.AuthType != "" {
+		i, err := io.WriteString(w, "authtype="+c.AuthType+"\n")
+		n += int64(i)
+		if err != nil {
+			return n, err
+		}
+	}
+
+	if c.Credential != "" {
+		i, err := io.WriteString(w, "credential="+c.Credential+"\n")
+		n += int64(i)
+		if err != nil {
+			return n, err
+		}
+	}
+
 	if c.Protocol != "" {
 		i, err := io.WriteString(w, "protocol="+c.Protocol+"\n")
 		n += int64(i)
@@ -93,6 +198,14 @@ func (c *gitCredentials) WriteTo(w io.Writer) (int64, error) {
 		}
 	}
 
+	for _, a := range c.Attributes {
+		i, err := io.WriteString(w, a.Key+"="+a.Value+"\n")
+		n += int64(i)
+		if err != nil {
+			return n, err
+		}
+	}
+
 	return n, nil
 }
 
@@ -125,6 +238,12 @@ func parseGitCredentials(r io.Reader) (*gitCredentials, error) {
 
 		val = strings.TrimSuffix(val, "\n")
 		switch key {
+		case "capability[]":
+			c.Capabilities = append(c.Capabilities, val)
+		case "authtype":
+			c.AuthType = val
+		case "credential":
+			c.Credential = val
 		case "protocol":
 			c.Protocol = val
 		case "host":
@@ -139,12 +258,52 @@ func parseGitCredentials(r io.Reader) (*gitCredentials, error) {
 			c.PasswordExpiryUTC = val
 		case "oauth_refresh_token":
 			c.OAuthRefreshToken = val
+		default:
+			if strings.HasSuffix(key, "[]") && val == "" {
+				// an empty value resets an array attribute
+				c.Del(key)
+
+				continue
+			}
+			c.Add(key, val)
 		}
 	}
 }
 
 type gc struct {
-	gp gopass.Store
+	gp       gopass.Store
+	cfg      configGetter
+	cacheDir string
+	// explain receives the reasoning of the lookup. It is only set by resolve
+	// which must not have any side effects.
+	explain func(format string, args ...any)
+}
+
+// newAPI initializes the gopass API. It is replaced in tests.
+var newAPI = func(ctx context.Context) (gopass.Store, error) {
+	return api.New(ctx)
+}
+
+// ensureAPI initializes the gopass API for commands that only need it sometimes.
+func (s *gc) ensureAPI(ctx context.Context) error {
+	if s.gp != nil {
+		return nil
+	}
+
+	gp, err := newAPI(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to initialize gopass API: %w", err)
+	}
+	s.gp = gp
+
+	return nil
+}
+
+// why records a step of the lookup if it is being explained.
+func (s *gc) why(format string, args ...any) {
+	if s.explain != nil {
+		s.explain(format, args...)
+	}
 }
 
 // Before is executed before another git-credential command.
@@ -157,31 +316,46 @@ func (s *gc) Before(ctx context.Context, cmd *cli.Command) (context.Context, err
 	return ctx, nil
 }
 
+// filter returns the entries starting with prefix that are not nested any deeper,
+// so a host level lookup does not pick up the entries of individual repositories.
 func filter(ls []string, prefix string) []string {
 	out := make([]string, 0, len(ls))
 	for _, e := range ls {
 		if !strings.HasPrefix(e, prefix) {
 			continue
 		}
+		if strings.Contains(strings.TrimPrefix(strings.TrimPrefix(e, prefix), "/"), "/") {
+			continue
+		}
 		out = append(out, e)
 	}
 
 	return out
 }
 
-func composePath(cmd *cli.Command, cred *gitCredentials) string {
-	store := cmd.String("store") + "/"
-	if store == "/" {
-		store = ""
-	}
+// composePath returns the secret path for the given credentials in the given store.
+func (s *gc) composePath(ctx context.Context, cmd *cli.Command, store string, cred *gitCredentials) (string, error) {
+	return renderPath(s.pathTemplate(ctx, cmd, cred), store, cred)
+}
 
-	path := store + "git/" + fsutil.CleanFilename(cred.Host)
-	if cred.Path != "" {
-		path += "/" + fsutil.CleanFilename(cred.Path)
+// candidatePaths returns the secret path for the given credentials in the given store
+// followed by the path earlier versions used if it differs. Entries that were not
+// renamed by migrate yet are still found this way.
+func (s *gc) candidatePaths(ctx context.Context, cmd *cli.Command, store string, cred *gitCredentials) ([]string, error) {
+	tmpl := s.pathTemplate(ctx, cmd, cred)
+	path, err := renderPath(tmpl, store, cred)
+	if err != nil {
+		return nil, err
+	}
+	legacy, err := renderLegacyPath(tmpl, store, cred)
+	if err != nil {
+		return nil, err
+	}
+	if legacy == path {
+		return []string{path}, nil
 	}
-	path += "/" + fsutil.CleanFilename(cred.Username)
 
-	return path
+	return []string{path, legacy}, nil
 }
 
 // Get returns a credential to git.
@@ -191,50 +365,72 @@ func (s *gc) Get(ctx context.Context, cmd *cli.Command) error {
 	if err != nil {
 		return fmt.Errorf("error: %w while parsing git-credential", err)
 	}
-	// try git/host/username... If username is empty, simply try git/host
+	if s.knownMiss(ctx, cmd, cred) {
+		debug.Log("no credential for %s, cached", credentialURL(cred))
 
-	path := composePath(cmd, cred)
-	if _, err := s.gp.Get(ctx, path, "latest"); err != nil {
-		// if the looked up path is a directory with only one entry (e.g. one user per host), take the subentry instead
-		ls, err := s.gp.List(ctx)
-		if err != nil {
-			return fmt.Errorf("error: %w while listing the storage", err)
+		return nil
+	}
+
+	ttl := s.cacheTimeout(ctx, cred)
+	var secret gopass.Secret
+	if ttl > 0 {
+		secret = s.cacheGet(ctx, cmd, cred)
+	}
+	if secret == nil {
+		if err := s.ensureAPI(ctx); err != nil {
+			return err
 		}
-		entries := filter(ls, path)
-		if len(entries) < 1 {
-			// no entry found, this is not an error
-			return nil
+		// try git/host/path/username, then the parent paths and the host... If username is empty, simply try git/host
+		m, err := s.find(ctx, cmd, cred)
+		if err != nil {
+			return err
 		}
-		if len(entries) > 1 {
-			fmt.Fprintln(os.Stderr, "gopass error: too many entries")
+		if m == nil {
+			s.recordMiss(ctx, cmd, cred)
 
 			return nil
 		}
-		path = entries[0]
+		secret = m.secret
+		if ttl > 0 {
+			s.cachePut(ctx, cmd, cred, m.path, secret, ttl)
+		}
 	}
-	secret, err := s.gp.Get(ctx, path, "latest")
+
+	cred.negotiateCapabilities()
+	cred.fill(secret)
+
+	_, err = cred.WriteTo(Stdout)
 	if err != nil {
-		return err
+		return fmt.Errorf("could not write to stdout: %w", err)
 	}
 
-	cred.Password = secret.Password()
+	return nil
+}
+
+// fill sets the reply to git from the secret.
+func (c *gitCredentials) fill(secret gopass.Secret) {
+	// the server challenges are input only, state[] is passed back to git untouched
+	challenges := c.AuthSchemes()
+	c.Del("wwwauth[]")
 	if username, _ := secret.Get("login"); username != "" {
 		// leave the username as is otherwise
-		cred.Username = username
+		c.Username = username
+	}
+	if authType, _ := secret.Get("authtype"); authType != "" && c.HasCapability(capabilityAuthType) &&
+		(len(challenges) == 0 || slices.Contains(challenges, strings.ToLower(authType))) {
+		// git can handle a pre-encoded Authorization value, e.g. a Bearer token
+		c.AuthType = authType
+		c.Credential = secret.Password()
+	} else {
+		// older git versions only understand the basic username/password pair
+		c.Password = secret.Password()
 	}
 	if expiry, _ := secret.Get("password_expiry_utc"); expiry != "" {
-		cred.PasswordExpiryUTC = expiry
+		c.PasswordExpiryUTC = expiry
 	}
 	if rt, _ := secret.Get("oauth_refresh_token"); rt != "" {
-		cred.OAuthRefreshToken = rt
+		c.OAuthRefreshToken = rt
 	}
-
-	_, err = cred.WriteTo(Stdout)
-	if err != nil {
-		return fmt.Errorf("could not write to stdout: %w", err)
-	}
-
-	return nil
 }
 
 // Store stores a credential got from git.
@@ -244,35 +440,118 @@ func (s *gc) Store(ctx context.Context, cmd *cli.Command) error {
 		return fmt.Errorf("error: %w while parsing git-credential", err)
 	}
 
-	path := composePath(cmd, cred)
-	// This should never really be an issue because git automatically removes invalid credentials first
-	if _, err := s.gp.Get(ctx, path, "latest"); err == nil {
-		debug.Log(""+
-			"gopass: did not store \"%s\" because it already exists. "+
-			"If you want to overwrite it, delete it first by doing: "+
-			"\"gopass rm %s\"\n",
-			path, path,
-		)
+	t, err := s.targetPath(ctx, cmd, cred)
+	if err != nil {
+		return err
+	}
+	path, shared := t.path, t.shared
+	debug.Log("storing %q, server challenges: %v", path, cred.AuthSchemes())
+	s.cacheInvalidate(ctx, cred, path)
+	if shared {
+		// other hosts resolve to the entry, too
+		s.forgetMisses("")
+	} else {
+		s.forgetMisses(cred.Host)
+	}
+	// git only stores credentials the server accepted
+	s.accepted(path)
+
+	secret, err := s.gp.Get(ctx, path, "latest")
+	switch {
+	case err != nil:
+		secret = secrets.New()
+		if cred.Username != "" {
+			_ = secret.Set("login", cred.Username)
+		}
+	case !credentialChanged(secret, cred, shared):
+		debug.Log("did not store %q because it is unchanged", path)
+
+		return nil
+	default:
+		// a rotated token, update it in place and keep any fields added by hand.
+		// The previous value is still available from the gopass history.
+		debug.Log("updating %q", path)
+	}
+	setCredential(secret, cred, shared)
+
+	if err := s.gp.Set(ctx, path, secret); err != nil {
+		fmt.Fprintf(os.Stderr, "gopass error: error while writing to store: %s\n", err)
 
 		return nil
 	}
-	secret := secrets.New()
-	secret.SetPassword(cred.Password)
-	if cred.Username != "" {
-		_ = secret.Set("login", cred.Username)
+	s.updatePathIndex(ctx, cred, []string{path}, nil)
+
+	return nil
+}
+
+// credentialSecret returns the password or pre-encoded credential git sent.
+func credentialSecret(cred *gitCredentials) string {
+	if cred.AuthType != "" && cred.Credential != "" {
+		return cred.Credential
+	}
+
+	return cred.Password
+}
+
+// credentialChanged returns true if git sent a different password, expiry, refresh token,
+// protocol or port than the one stored in the secret, see originChanged.
+func credentialChanged(secret gopass.Secret, cred *gitCredentials, shared bool) bool {
+	if isInvalid(secret) || secret.Password() != credentialSecret(cred) || originChanged(secret, cred, shared) {
+		return true
+	}
+	if expiry, _ := secret.Get("password_expiry_utc"); expiry != cred.PasswordExpiryUTC {
+		return true
+	}
+	// git only sends a refresh token if it got one, keep the stored one otherwise
+	rt, _ := secret.Get("oauth_refresh_token")
+
+	return cred.OAuthRefreshToken != "" && rt != cred.OAuthRefreshToken
+}
+
+// setCredential writes the credential fields git sent to the secret. Other fields are kept.
+// Shared is true if the entry is shared with other hosts, see setOrigin.
+func setCredential(secret gopass.Secret, cred *gitCredentials, shared bool) {
+	secret.SetPassword(credentialSecret(cred))
+	if cred.AuthType != "" && cred.Credential != "" {
+		_ = secret.Set("authtype", cred.AuthType)
 	}
 	if cred.PasswordExpiryUTC != "" {
 		_ = secret.Set("password_expiry_utc", cred.PasswordExpiryUTC)
+	} else {
+		// the expiry of the previous token does not apply to the new one
+		_ = secret.Del("password_expiry_utc")
 	}
 	if cred.OAuthRefreshToken != "" {
 		_ = secret.Set("oauth_refresh_token", cred.OAuthRefreshToken)
 	}
+	setOrigin(secret, cred, shared)
+	_ = secret.Del("invalid_since")
+	_ = secret.Set("stored_at", time.Now().UTC().Format(time.RFC3339))
+}
 
-	if err := s.gp.Set(ctx, path, secret); err != nil {
-		fmt.Fprintf(os.Stderr, "gopass error: error while writing to store: %s\n", err)
+// sharedPath returns the entry listing the host in its aliases field or the wildcard
+// entry matching it, so that store and erase change that entry instead of creating
+// a copy for the host. It returns an empty string if there is none.
+func (s *gc) sharedPath(ctx context.Context, cmd *cli.Command, cred *gitCredentials) string {
+	if alias := s.aliasPath(ctx, cmd, cred); alias != "" {
+		debug.Log("%s is an alias of %q", cred.Host, alias)
+
+		return alias
 	}
 
-	return nil
+	m, err := s.findByWildcard(ctx, cmd, cred, s.lister(ctx, cmd, cred), false)
+	if err != nil {
+		debug.Log("failed to look up wildcard entries for %s: %s", cred.Host, err)
+
+		return ""
+	}
+	if m != nil {
+		debug.Log("%s matches the wildcard entry %q", cred.Host, m.path)
+
+		return m.path
+	}
+
+	return ""
 }
 
 // Erase removes a credential got from git.
@@ -282,14 +561,101 @@ func (s *gc) Erase(ctx context.Context, cmd *cli.Command) error {
 		return fmt.Errorf("error: %w while parsing git-credential", err)
 	}
 
-	path := composePath(cmd, cred)
-	if err := s.gp.Remove(ctx, path); err != nil {
-		fmt.Fprintln(os.Stderr, "gopass error: error while writing to store")
+	t, err := s.targetPath(ctx, cmd, cred)
+	if err != nil {
+		return err
+	}
+	path := t.path
+	debug.Log("erasing %q, server challenges: %v", path, cred.AuthSchemes())
+	s.cacheInvalidate(ctx, cred, path)
+	if t.own != "" {
+		// the entry serves other repositories or ports, too. Remember the rejection
+		// so that the credential git stores next only overrides it for this request.
+		s.rejected(t.own)
+		fmt.Fprintf(os.Stderr, "gopass: keeping %q, it is shared with other repositories and was only rejected for %s, a new credential is stored in %q\n", path, credentialURL(cred), t.own)
+
+		return nil
+	}
+	if pattern, ok := wildcardOf(path); ok {
+		// one host rejecting the credential, e.g. a review app that is being torn down,
+		// says nothing about the other hosts matching the pattern
+		fmt.Fprintf(os.Stderr, "gopass: keeping %q, it is shared by all hosts matching %s and was only rejected by %s\n", path, pattern, cred.Host)
+
+		return nil
+	}
+	if err := s.discard(ctx, cred, path); err != nil {
+		fmt.Fprintf(os.Stderr, "gopass error: error while writing to store: %s\n", err)
 	}
 
 	return nil
 }
 
+// target is the entry store and erase change for a credential request, see targetPath.
+type target struct {
+	path string
+	// shared is true if the entry is not the entry of the requested host and port
+	shared bool
+	// own is the path of an entry just for the request if path is the entry of a
+	// parent path or of the host without the port, see lookupChain. It is empty otherwise.
+	own string
+}
+
+// targetPath returns the entry store and erase change for the credentials git sent.
+// That is the entry get resolves them to: the first existing entry on the lookupChain
+// of the canonical host in the stores in priority order, so an org-wide or host-wide
+// entry is updated instead of creating a copy for each repository. Once such an entry
+// was rejected for the request, the full path is used instead so that the new
+// credential only overrides it for this repository. Entries that were not migrated yet
+// are found at their legacy path. Otherwise it is the entry listing the host in its
+// aliases field or the wildcard entry matching it. It defaults to the full path in the
+// target store.
+func (s *gc) targetPath(ctx context.Context, cmd *cli.Command, cred *gitCredentials) (*target, error) {
+	c := s.canonical(ctx, cred)
+	for _, store := range searchStores(cmd) {
+		for i, link := range lookupChain(c) {
+			paths, err := s.candidatePaths(ctx, cmd, store, link)
+			if err != nil {
+				return nil, err
+			}
+			for _, path := range paths {
+				if _, err := s.gp.Get(ctx, path, "latest"); err != nil {
+					continue
+				}
+				t := &target{path: path, shared: link.Host != cred.Host}
+				if i == 0 {
+					return t, nil
+				}
+
+				own, err := s.composePath(ctx, cmd, targetStore(cmd), c)
+				if err != nil {
+					return nil, err
+				}
+				if loadRejections(s.cachePath("rejections.json"))[own] > 0 {
+					debug.Log("%q was rejected for %s, using %q", path, credentialURL(cred), own)
+
+					return &target{path: own, shared: c != cred}, nil
+				}
+				t.own = own
+
+				return t, nil
+			}
+		}
+	}
+
+	if c == cred {
+		if shared := s.sharedPath(ctx, cmd, cred); shared != "" {
+			return &target{path: shared, shared: true}, nil
+		}
+	}
+
+	path, err := s.composePath(ctx, cmd, targetStore(cmd), c)
+	if err != nil {
+		return nil, err
+	}
+
+	return &target{path: path, shared: c != cred}, nil
+}
+
 // Configure configures gopass as git's credential.helper.
 func (s *gc) Configure(ctx context.Context, cmd *cli.Command) error {
 	options, err := getOptions(cmd)
@@ -331,12 +697,33 @@ func getOptions(cmd *cli.Command) ([]string, error) {
 	}
 
 	options = append(options, "config", flag, "credential.helper")
-	store := "gopass"
+	helper := "gopass"
 	if s := cmd.String("store"); s != "" {
-		store = fmt.Sprintf("gopass --store=%s", s)
+		helper += fmt.Sprintf(" --store=%s", s)
+	}
+	if s := cmd.String("target-store"); s != "" {
+		if strings.Contains(s, ",") {
+			return options, fmt.Errorf("only specify one target store")
+		}
+		helper += fmt.Sprintf(" --target-store=%s", s)
+	}
+	if cmd.Bool("search-urls") {
+		helper += " --search-urls"
+	}
+	if tmpl := cmd.String("path-template"); tmpl != "" {
+		if _, err := renderPath(tmpl, "", &gitCredentials{}); err != nil {
+			return options, err
+		}
+		// git runs the helper through the shell
+		helper += " --path-template=" + shellQuote(tmpl)
 	}
 
-	options = append(options, store)
+	options = append(options, helper)
 
 	return options, nil
 }
+
+// shellQuote quotes s for use as a single shell word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
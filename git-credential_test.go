@@ -9,8 +9,10 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/gopasspw/git-credential-gopass/helpers/githost/githttp"
@@ -18,6 +20,7 @@ import (
 	"github.com/gopasspw/gopass/pkg/ctxutil"
 	"github.com/gopasspw/gopass/pkg/fsutil"
 	"github.com/gopasspw/gopass/pkg/gopass/apimock"
+	"github.com/gopasspw/gopass/pkg/gopass/secrets"
 	"github.com/gopasspw/gopass/pkg/termio"
 	"github.com/gopasspw/gopass/tests/gptest"
 	"github.com/stretchr/testify/assert"
@@ -26,12 +29,15 @@ import (
 )
 
 // testCmd creates a *cli.Command with the given flags set for use in tests.
-func testCmd(t *testing.T, ctx context.Context, flags map[string]string) *cli.Command {
+func testCmd(t testing.TB, ctx context.Context, flags map[string]string) *cli.Command {
 	t.Helper()
 
 	cmd := &cli.Command{
 		Flags: []cli.Flag{
 			&cli.StringFlag{Name: "store"},
+			&cli.StringFlag{Name: "path-template"},
+			&cli.StringFlag{Name: "target-store"},
+			&cli.BoolFlag{Name: "search-urls"},
 			&cli.BoolFlag{Name: "global"},
 			&cli.BoolFlag{Name: "local"},
 			&cli.BoolFlag{Name: "system"},
@@ -71,6 +77,24 @@ func TestGitCredentialFormat(t *testing.T) {
 			"password_expiry_utc=2000\n" +
 			"oauth_refresh_token=xyzzy\n",
 		),
+		strings.NewReader("" +
+			"capability[]=authtype\n" +
+			"capability[]=state\n" +
+			"protocol=https\n" +
+			"host=example.com\n" +
+			"authtype=Bearer\n" +
+			"credential=t0ken\n",
+		),
+		strings.NewReader("" +
+			"protocol=https\n" +
+			"host=example.com\n" +
+			"wwwauth[]=Basic realm=\"example\"\n" +
+			"state[]=foo\n" +
+			"wwwauth[]=Bearer realm=\"example\"\n" +
+			"state[]=\n" +
+			"state[]=helper:bar\n" +
+			"foo=bar\n",
+		),
 		strings.NewReader("" +
 			"protocol=https\n" +
 			"host=example.com\n" +
@@ -91,11 +115,12 @@ func TestGitCredentialFormat(t *testing.T) {
 
 	results := []gitCredentials{
 		{
-			Host:     "example.com",
-			Password: "secr3=t",
-			Path:     "test",
-			Protocol: "https",
-			Username: "bob",
+			Host:       "example.com",
+			Password:   "secr3=t",
+			Path:       "test",
+			Protocol:   "https",
+			Username:   "bob",
+			Attributes: []gitAttribute{{Key: "foo", Value: "bar"}},
 		},
 		{
 			Host:              "example.com",
@@ -105,12 +130,30 @@ func TestGitCredentialFormat(t *testing.T) {
 			Username:          "bob",
 			PasswordExpiryUTC: "2000",
 			OAuthRefreshToken: "xyzzy",
+			Attributes:        []gitAttribute{{Key: "foo", Value: "bar"}},
+		},
+		{
+			Capabilities: []string{"authtype", "state"},
+			AuthType:     "Bearer",
+			Credential:   "t0ken",
+			Host:         "example.com",
+			Protocol:     "https",
+		},
+		{
+			Host:     "example.com",
+			Protocol: "https",
+			Attributes: []gitAttribute{
+				{Key: "wwwauth[]", Value: `Basic realm="example"`},
+				{Key: "wwwauth[]", Value: `Bearer realm="example"`},
+				{Key: "state[]", Value: "helper:bar"},
+				{Key: "foo", Value: "bar"},
+			},
 		},
 		{},
 		{},
 	}
 
-	expectsErr := []bool{false, false, true, true}
+	expectsErr := []bool{false, false, false, false, true, true}
 	for i := range data {
 		result, err := parseGitCredentials(data[i])
 		if expectsErr[i] {
@@ -135,7 +178,8 @@ func TestGitCredentialFormat(t *testing.T) {
 func TestGitCredentialHelper(t *testing.T) { //nolint:paralleltest
 	ctx := t.Context()
 	act := &gc{
-		gp: apimock.New(),
+		gp:  apimock.New(),
+		cfg: mapConfig{},
 	}
 	require.NoError(t, act.gp.Set(ctx, "foo", &apimock.Secret{Buf: []byte("bar")}))
 
@@ -201,10 +245,190 @@ func TestGitCredentialHelper(t *testing.T) { //nolint:paralleltest
 	require.Error(t, act.Erase(ctx, cmd))
 }
 
+func TestGitCredentialHelperAuthType(t *testing.T) { //nolint:paralleltest
+	ctx := t.Context()
+	act := &gc{
+		gp:  apimock.New(),
+		cfg: mapConfig{},
+	}
+
+	stdout := &bytes.Buffer{}
+	Stdout = stdout
+	color.NoColor = true
+	defer func() {
+		Stdout = os.Stdout
+		termio.Stdin = os.Stdin
+	}()
+
+	cmd := testCmd(t, ctx, nil)
+	ctx = ctxutil.WithStdin(ctx, true)
+
+	s := "protocol=https\n" +
+		"host=example.com\n" +
+		"username=bob\n"
+
+	termio.Stdin = strings.NewReader("capability[]=authtype\n" + s + "authtype=Bearer\ncredential=t0ken\n")
+	require.NoError(t, act.Store(ctx, cmd))
+
+	sec, err := act.gp.Get(ctx, "git/example.com/bob", "latest")
+	require.NoError(t, err)
+	assert.Equal(t, "t0ken", sec.Password())
+	authType, _ := sec.Get("authtype")
+	assert.Equal(t, "Bearer", authType)
+
+	// git advertises authtype support, return the pre-encoded credential
+	termio.Stdin = strings.NewReader("capability[]=authtype\ncapability[]=unknown\n" + s)
+	require.NoError(t, act.Get(ctx, cmd))
+	read, err := parseGitCredentials(stdout)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"authtype"}, read.Capabilities)
+	assert.Equal(t, "Bearer", read.AuthType)
+	assert.Equal(t, "t0ken", read.Credential)
+	assert.Empty(t, read.Password)
+	stdout.Reset()
+
+	// older git, fall back to the basic pair
+	termio.Stdin = strings.NewReader(s)
+	require.NoError(t, act.Get(ctx, cmd))
+	read, err = parseGitCredentials(stdout)
+	require.NoError(t, err)
+	assert.Empty(t, read.Capabilities)
+	assert.Empty(t, read.AuthType)
+	assert.Empty(t, read.Credential)
+	assert.Equal(t, "bob", read.Username)
+	assert.Equal(t, "t0ken", read.Password)
+	stdout.Reset()
+
+	// the server only accepts basic auth, do not hand out the bearer token
+	termio.Stdin = strings.NewReader("capability[]=authtype\n" + s + "wwwauth[]=Basic realm=\"x\"\nstate[]=helper:foo\n")
+	require.NoError(t, act.Get(ctx, cmd))
+	read, err = parseGitCredentials(stdout)
+	require.NoError(t, err)
+	assert.Empty(t, read.AuthType)
+	assert.Equal(t, "t0ken", read.Password)
+	assert.Empty(t, read.Values("wwwauth[]"))
+	assert.Equal(t, []string{"helper:foo"}, read.Values("state[]"))
+}
+
+func TestGitCredentialHelperStoreUpdate(t *testing.T) { //nolint:paralleltest
+	ctx := t.Context()
+	act := &gc{
+		gp:  apimock.New(),
+		cfg: mapConfig{},
+	}
+
+	stdout := &bytes.Buffer{}
+	Stdout = stdout
+	defer func() {
+		Stdout = os.Stdout
+		termio.Stdin = os.Stdin
+	}()
+
+	ctx = ctxutil.WithStdin(ctx, true)
+	cmd := testCmd(t, ctx, nil)
+
+	sec := secrets.New()
+	sec.SetPassword("old")
+	require.NoError(t, sec.Set("login", "bob"))
+	require.NoError(t, sec.Set("url", "https://example.com/bob"))
+	require.NoError(t, sec.Set("protocol", "https"))
+	require.NoError(t, sec.Set("comment", "added by hand"))
+	require.NoError(t, sec.Set("password_expiry_utc", "1000"))
+	require.NoError(t, sec.Set("oauth_refresh_token", "refresh-1"))
+	require.NoError(t, sec.Set("stored_at", "2020-01-01T00:00:00Z"))
+	require.NoError(t, act.gp.Set(ctx, "git/example.com/bob", sec))
+
+	s := "protocol=https\nhost=example.com\nusername=bob\n"
+
+	// unchanged credentials are not written again
+	termio.Stdin = strings.NewReader(s + "password=old\npassword_expiry_utc=1000\n")
+	require.NoError(t, act.Store(ctx, cmd))
+	got, err := act.gp.Get(ctx, "git/example.com/bob", "latest")
+	require.NoError(t, err)
+	storedAt, _ := got.Get("stored_at")
+	assert.Equal(t, "2020-01-01T00:00:00Z", storedAt)
+
+	// a rotated token is updated in place
+	termio.Stdin = strings.NewReader(s + "password=new\npassword_expiry_utc=2000\noauth_refresh_token=refresh-2\n")
+	require.NoError(t, act.Store(ctx, cmd))
+	got, err = act.gp.Get(ctx, "git/example.com/bob", "latest")
+	require.NoError(t, err)
+	assert.Equal(t, "new", got.Password())
+	for k, want := range map[string]string{
+		"login":               "bob",
+		"url":                 "https://example.com/bob",
+		"comment":             "added by hand",
+		"password_expiry_utc": "2000",
+		"oauth_refresh_token": "refresh-2",
+	} {
+		v, _ := got.Get(k)
+		assert.Equal(t, want, v, k)
+	}
+	storedAt, _ = got.Get("stored_at")
+	assert.NotEqual(t, "2020-01-01T00:00:00Z", storedAt)
+
+	// the refresh token is kept if git does not send one, a stale expiry is not
+	termio.Stdin = strings.NewReader(s + "password=newer\n")
+	require.NoError(t, act.Store(ctx, cmd))
+	got, err = act.gp.Get(ctx, "git/example.com/bob", "latest")
+	require.NoError(t, err)
+	assert.Equal(t, "newer", got.Password())
+	rt, _ := got.Get("oauth_refresh_token")
+	assert.Equal(t, "refresh-2", rt)
+	assert.NotContains(t, got.Keys(), "password_expiry_utc")
+}
+
+func TestGitCredentialHelperExpired(t *testing.T) { //nolint:paralleltest
+	ctx := t.Context()
+	act := &gc{
+		gp:  apimock.New(),
+		cfg: mapConfig{},
+	}
+
+	stdout := &bytes.Buffer{}
+	Stdout = stdout
+	color.NoColor = true
+	defer func() {
+		Stdout = os.Stdout
+		termio.Stdin = os.Stdin
+	}()
+
+	cmd := testCmd(t, ctx, nil)
+	ctx = ctxutil.WithStdin(ctx, true)
+
+	expired := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	valid := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+
+	termio.Stdin = strings.NewReader("protocol=https\nhost=example.com\nusername=bob\npassword=old\npassword_expiry_utc=" + expired + "\n")
+	require.NoError(t, act.Store(ctx, cmd))
+	termio.Stdin = strings.NewReader("protocol=https\nhost=example.com\nusername=alice\npassword=new\npassword_expiry_utc=" + valid + "\n")
+	require.NoError(t, act.Store(ctx, cmd))
+
+	// the exact entry is expired
+	termio.Stdin = strings.NewReader("protocol=https\nhost=example.com\nusername=bob\n")
+	require.NoError(t, act.Get(ctx, cmd))
+	assert.Empty(t, stdout.String())
+
+	// the expired entry is skipped, the other one is used
+	termio.Stdin = strings.NewReader("protocol=https\nhost=example.com\n")
+	require.NoError(t, act.Get(ctx, cmd))
+	read, err := parseGitCredentials(stdout)
+	require.NoError(t, err)
+	assert.Equal(t, "new", read.Password)
+	assert.Equal(t, "alice", read.Username)
+	assert.Equal(t, valid, read.PasswordExpiryUTC)
+
+	// the expired secret is left in place
+	sec, err := act.gp.Get(ctx, "git/example.com/bob", "latest")
+	require.NoError(t, err)
+	assert.Equal(t, "old", sec.Password())
+}
+
 func TestGitCredentialHelperWithStoreFlag(t *testing.T) { //nolint:paralleltest
 	ctx := t.Context()
 	act := &gc{
-		gp: apimock.New(),
+		gp:  apimock.New(),
+		cfg: mapConfig{},
 	}
 
 	stdout := &bytes.Buffer{}
@@ -286,6 +510,36 @@ func Test_getOptions(t *testing.T) {
 			want:    []string{"config", "--local", "credential.helper", "gopass --store=teststore"},
 			wantErr: false,
 		},
+		{
+			name:    "with store list and target store",
+			args:    args{cmd: testCmd(t, t.Context(), map[string]string{"store": "work,/", "target-store": "work"})},
+			want:    []string{"config", "--global", "credential.helper", "gopass --store=work,/ --target-store=work"},
+			wantErr: false,
+		},
+		{
+			name:    "error case with several target stores",
+			args:    args{cmd: testCmd(t, t.Context(), map[string]string{"target-store": "work,/"})},
+			want:    []string{"config", "--global", "credential.helper"},
+			wantErr: true,
+		},
+		{
+			name:    "with url search",
+			args:    args{cmd: testCmd(t, t.Context(), map[string]string{"search-urls": "true"})},
+			want:    []string{"config", "--global", "credential.helper", "gopass --search-urls"},
+			wantErr: false,
+		},
+		{
+			name:    "with path template",
+			args:    args{cmd: testCmd(t, t.Context(), map[string]string{"path-template": "{{.Store}}/creds/{{.Host}}/{{.User}}"})},
+			want:    []string{"config", "--global", "credential.helper", "gopass --path-template='{{.Store}}/creds/{{.Host}}/{{.User}}'"},
+			wantErr: false,
+		},
+		{
+			name:    "error case with invalid path template",
+			args:    args{cmd: testCmd(t, t.Context(), map[string]string{"path-template": "{{.Nope}}"})},
+			want:    []string{"config", "--global", "credential.helper"},
+			wantErr: true,
+		},
 		{
 			name:    "error case with too many scope flags",
 			args:    args{cmd: testCmd(t, t.Context(), map[string]string{"local": "true", "system": "true"})},
@@ -404,6 +658,8 @@ func Test_composePath(t *testing.T) {
 		name        string
 		credentials *gitCredentials
 		store       string
+		template    string
+		config      mapConfig
 		expected    string
 	}{
 		{
@@ -434,7 +690,7 @@ func Test_composePath(t *testing.T) {
 				Path:     "user/myrepo.git",
 			},
 			store:    "",
-			expected: "git/github.com/user_myrepo.git/alice",
+			expected: "git/github.com/user_myrepo/alice",
 		},
 		{
 			name: "with store prefix",
@@ -486,6 +742,100 @@ func Test_composePath(t *testing.T) {
 			store:    "",
 			expected: "git/github.com/repo2/alice",
 		},
+		{
+			name: "with port",
+			credentials: &gitCredentials{
+				Host:     "example.com:8080",
+				Username: "alice",
+			},
+			expected: "git/example.com_8080/alice",
+		},
+		{
+			name: "with normalised host and repository path",
+			credentials: &gitCredentials{
+				Protocol: "https",
+				Host:     "GitHub.COM:443",
+				Username: "alice",
+				Path:     "/org/repo.git/",
+			},
+			expected: "git/github.com/org_repo/alice",
+		},
+		{
+			name: "with IDN host",
+			credentials: &gitCredentials{
+				Protocol: "https",
+				Host:     "Bücher.example:8443",
+				Username: "alice",
+			},
+			expected: "git/xn--bcher-kva.example_8443/alice",
+		},
+		{
+			name: "with IPv6 literal",
+			credentials: &gitCredentials{
+				Host:     "[::1]:8080",
+				Username: "alice",
+			},
+			expected: "git/%3A%3A1_8080/alice",
+		},
+		{
+			name: "with email as username",
+			credentials: &gitCredentials{
+				Host:     "example.com",
+				Username: "alice@example.com",
+			},
+			expected: "git/example.com/alice@example.com",
+		},
+		{
+			name: "with underscore and colon in repository path",
+			credentials: &gitCredentials{
+				Host:     "example.com",
+				Username: "John Doe",
+				Path:     "org/my_repo:v2",
+			},
+			expected: "git/example.com/org_my%5Frepo%3Av2/John%20Doe",
+		},
+		{
+			name: "with path template",
+			credentials: &gitCredentials{
+				Protocol: "https",
+				Host:     "example.com:8080",
+				Username: "alice",
+				Path:     "org/repo",
+			},
+			store:    "work",
+			template: "{{.Store}}/creds/{{.Protocol}}/{{.Host}}/{{.Port}}/{{.Path}}/{{.User}}",
+			expected: "work/creds/https/example.com/8080/org_repo/alice",
+		},
+		{
+			name: "with path template without store",
+			credentials: &gitCredentials{
+				Protocol: "https",
+				Host:     "example.com",
+				Username: "alice",
+			},
+			template: "{{.Store}}/forges/{{.Host}}/{{.User}}",
+			expected: "forges/example.com/alice",
+		},
+		{
+			name: "with path template from git config",
+			credentials: &gitCredentials{
+				Host:     "example.com",
+				Username: "alice",
+			},
+			store:    "work",
+			config:   mapConfig{"credential-gopass.pathTemplate": "{{.Store}}/forges/{{.Host}}/{{.User}}"},
+			expected: "work/forges/example.com/alice",
+		},
+		{
+			name: "flag takes precedence over git config",
+			credentials: &gitCredentials{
+				Host:     "example.com",
+				Username: "alice",
+			},
+			template: "web/{{.Host}}/{{.User}}",
+			config:   mapConfig{"credential-gopass.pathTemplate": "{{.Store}}/forges/{{.Host}}/{{.User}}"},
+			expected: "web/example.com/alice",
+		},
 	}
 
 	for _, tt := range tests {
@@ -493,19 +843,83 @@ func Test_composePath(t *testing.T) {
 			t.Parallel()
 
 			cmd := testCmd(t, t.Context(), map[string]string{
-				"store": tt.store,
+				"store":         tt.store,
+				"path-template": tt.template,
 			})
 
-			got := composePath(cmd, tt.credentials)
+			act := &gc{cfg: tt.config}
+			if tt.config == nil {
+				act.cfg = mapConfig{}
+			}
+
+			got, err := act.composePath(t.Context(), cmd, targetStore(cmd), tt.credentials)
+			require.NoError(t, err)
 			assert.Equal(t, tt.expected, got)
 		})
 	}
 }
 
+func Test_normalize(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		protocol string
+		host     string
+		path     string
+		wantHost string
+		wantPath string
+	}{
+		{"https", "github.com", "org/repo", "github.com", "org/repo"},
+		{"https", "GitHub.com", "org/repo.git", "github.com", "org/repo"},
+		{"https", "github.com:443", "org/repo/", "github.com", "org/repo"},
+		{"https", "github.com:80", "/org/repo.git/", "github.com:80", "org/repo"},
+		{"http", "github.com:80", "", "github.com", ""},
+		{"HTTPS", "github.com:443", "", "github.com", ""},
+		{"ssh", "github.com:22", "", "github.com", ""},
+		{"smtp", "mail.example.com:465", "", "mail.example.com:465", ""},
+		{"smtps", "mail.example.com:465", "", "mail.example.com", ""},
+		{"https", "bücher.example", "", "xn--bcher-kva.example", ""},
+		{"https", "xn--bcher-kva.example", "", "xn--bcher-kva.example", ""},
+		{"https", "BÜCHER.example:8443", "", "xn--bcher-kva.example:8443", ""},
+		{"https", "[::1]:443", "", "[::1]", ""},
+		{"https", "[::1]:8443", "", "[::1]:8443", ""},
+		{"https", "127.0.0.1:443", "", "127.0.0.1", ""},
+		{"https", "example.com", "repo.github.io", "example.com", "repo.github.io"},
+	} {
+		got := normalize(&gitCredentials{Protocol: tc.protocol, Host: tc.host, Path: tc.path})
+		assert.Equal(t, tc.wantHost, got.Host, "%s://%s/%s", tc.protocol, tc.host, tc.path)
+		assert.Equal(t, tc.wantPath, got.Path, "%s://%s/%s", tc.protocol, tc.host, tc.path)
+	}
+}
+
+func Test_encodePath(t *testing.T) {
+	t.Parallel()
+
+	for _, in := range []string{"", "org/repo", "org_repo", "org/my repo.git", ".hidden/x", "100%", "ünïcode", "a//b/"} {
+		got, err := decodePath(encodePath(in))
+		require.NoError(t, err)
+		assert.Equal(t, in, got)
+
+		got, err = decodeName(encodeName(in))
+		require.NoError(t, err)
+		assert.Equal(t, in, got)
+	}
+
+	// different inputs never collide
+	assert.NotEqual(t, encodePath("org/repo"), encodePath("org_repo"))
+	assert.Equal(t, "%2Ehidden", encodeName(".hidden"))
+
+	_, err := decodeName("100%")
+	require.Error(t, err)
+	_, err = decodeName("%zz")
+	require.Error(t, err)
+}
+
 func TestGitCredentialHelperMultipleCredentialsPerUser(t *testing.T) { //nolint:paralleltest
 	ctx := t.Context()
 	act := &gc{
-		gp: apimock.New(),
+		gp:  apimock.New(),
+		cfg: mapConfig{},
 	}
 
 	stdout := &bytes.Buffer{}
@@ -686,3 +1100,71 @@ func TestIssue19_CredentialsInClonedRepo(t *testing.T) {
 	assert.NotContains(t, credPath, cloneDir,
 		"Credential path should not be inside the cloned repository")
 }
+
+// fsListStore lists the secrets by walking a directory tree like the filesystem backend of gopass does.
+type fsListStore struct {
+	*apimock.MockAPI
+	dir string
+}
+
+func (f *fsListStore) List(context.Context) ([]string, error) {
+	var ls []string
+	err := filepath.WalkDir(f.dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(f.dir, path)
+		if err != nil {
+			return err
+		}
+		ls = append(ls, strings.TrimSuffix(filepath.ToSlash(rel), ".gpg"))
+
+		return nil
+	})
+
+	return ls, err
+}
+
+// BenchmarkGetFallback measures what the path index saves on a lookup that falls back to
+// listing. gopass can only list all secrets in all mounts at once, so without the index
+// every such lookup lists all of them.
+func BenchmarkGetFallback(b *testing.B) {
+	ctx := ctxutil.WithStdin(b.Context(), true)
+
+	gp := &fsListStore{MockAPI: apimock.New(), dir: b.TempDir()}
+	for i := range 5000 {
+		fn := filepath.Join(gp.dir, "websites", "site"+strconv.Itoa(i)+".example.com", "user.gpg")
+		require.NoError(b, os.MkdirAll(filepath.Dir(fn), 0o700))
+		require.NoError(b, os.WriteFile(fn, nil, 0o600))
+	}
+	sec := secrets.New()
+	sec.SetPassword("s3cret")
+	require.NoError(b, gp.Set(ctx, "git/example.com/bob", sec))
+	require.NoError(b, os.MkdirAll(filepath.Join(gp.dir, "git", "example.com"), 0o700))
+	require.NoError(b, os.WriteFile(filepath.Join(gp.dir, "git", "example.com", "bob.gpg"), nil, 0o600))
+
+	Stdout = io.Discard
+	defer func() {
+		Stdout = os.Stdout
+		termio.Stdin = os.Stdin
+	}()
+
+	for _, pathIndex := range []bool{false, true} {
+		b.Run("pathIndex="+strconv.FormatBool(pathIndex), func(b *testing.B) {
+			act := &gc{
+				gp:       gp,
+				cfg:      mapConfig{"credential-gopass.pathIndex": strconv.FormatBool(pathIndex)},
+				cacheDir: b.TempDir(),
+			}
+			cmd := testCmd(b, ctx, nil)
+
+			for b.Loop() {
+				// git did not send a username, so the exact path misses
+				termio.Stdin = strings.NewReader("protocol=https\nhost=example.com\n")
+				if err := act.Get(ctx, cmd); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
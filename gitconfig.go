@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gopasspw/gopass/pkg/debug"
+)
+
+// configSection is the git config section holding the settings of this helper.
+// Like any other credential setting they can be scoped to an URL, e.g.
+//
+//	[credential-gopass "https://git.example.com"]
+//		oauthTokenURL = https://git.example.com/oauth/token
+const configSection = "credential-gopass"
+
+// configGetter looks up git config values.
+type configGetter interface {
+	// Get returns the value of key best matching the given URL or an empty string if it is not set.
+	Get(ctx context.Context, key, url string) string
+}
+
+// gitConfig reads the configuration by invoking git.
+type gitConfig struct{}
+
+// Get implements configGetter.
+func (gitConfig) Get(ctx context.Context, key, url string) string {
+	args := []string{"config", "--get", key}
+	if url != "" {
+		args = []string{"config", "--get-urlmatch", key, url}
+	}
+
+	out, err := exec.CommandContext(ctx, "git", args...).Output()
+	if err != nil {
+		// git config exits with 1 if the key is not set
+		debug.Log("git config %s: %s", key, err)
+
+		return ""
+	}
+
+	return strings.TrimSpace(string(out))
+}
+
+// config returns the git config value of key matching the given credentials.
+func (s *gc) config(ctx context.Context, key string, cred *gitCredentials) string {
+	cfg := s.cfg
+	if cfg == nil {
+		cfg = gitConfig{}
+	}
+
+	return cfg.Get(ctx, key, credentialURL(cred))
+}
+
+// configDuration returns the git config value of key as a duration. Like the timeout
+// of git-credential-cache plain numbers are seconds. It returns zero if the key is
+// not set or invalid.
+func (s *gc) configDuration(ctx context.Context, key string, cred *gitCredentials) time.Duration {
+	v := s.config(ctx, key, cred)
+	if v == "" {
+		return 0
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			debug.Log("invalid %s %q", key, v)
+
+			return 0
+		}
+		d = time.Duration(n) * time.Second
+	}
+
+	return max(d, 0)
+}
+
+// credentialURL rebuilds the URL git is asking credentials for.
+func credentialURL(cred *gitCredentials) string {
+	if cred.Protocol == "" || cred.Host == "" {
+		return ""
+	}
+
+	u := &url.URL{
+		Scheme: cred.Protocol,
+		Host:   cred.Host,
+		Path:   "/" + cred.Path,
+	}
+
+	return u.String()
+}
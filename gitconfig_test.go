@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mapConfig is a configGetter for tests. It ignores the URL.
+type mapConfig map[string]string
+
+func (m mapConfig) Get(_ context.Context, key, _ string) string {
+	return m[key]
+}
+
+func Test_credentialURL(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		cred *gitCredentials
+		want string
+	}{
+		{cred: &gitCredentials{}, want: ""},
+		{cred: &gitCredentials{Host: "example.com"}, want: ""},
+		{cred: &gitCredentials{Protocol: "https", Host: "example.com"}, want: "https://example.com/"},
+		{cred: &gitCredentials{Protocol: "https", Host: "example.com:8443", Path: "org/repo.git"}, want: "https://example.com:8443/org/repo.git"},
+	} {
+		assert.Equal(t, tc.want, credentialURL(tc.cred))
+	}
+}
@@ -0,0 +1,106 @@
+package githttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+)
+
+// TokenHandler is a minimal stand-in for an OAuth2 token endpoint.
+// It only supports the refresh_token grant and rotates the refresh token
+// on every successful exchange.
+type TokenHandler struct {
+	ClientID  string
+	ExpiresIn int64
+
+	mu           sync.Mutex
+	refreshToken string
+	accessToken  string
+	issued       int
+}
+
+// NewTokenHandler creates a token endpoint that accepts the given refresh token.
+func NewTokenHandler(clientID, refreshToken string) *TokenHandler {
+	return &TokenHandler{
+		ClientID:     clientID,
+		ExpiresIn:    3600,
+		refreshToken: refreshToken,
+	}
+}
+
+// AccessToken returns the most recently issued access token.
+func (h *TokenHandler) AccessToken() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.accessToken
+}
+
+// RefreshToken returns the currently valid refresh token.
+func (h *TokenHandler) RefreshToken() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.refreshToken
+}
+
+// Issued returns the number of access tokens issued so far.
+func (h *TokenHandler) Issued() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.issued
+}
+
+func (h *TokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, "invalid_request")
+		return
+	}
+
+	if r.PostForm.Get("grant_type") != "refresh_token" {
+		log.Printf("Unsupported grant type %q from %s", r.PostForm.Get("grant_type"), r.RemoteAddr)
+		tokenError(w, "unsupported_grant_type")
+		return
+	}
+	if h.ClientID != "" && r.PostForm.Get("client_id") != h.ClientID {
+		log.Printf("Unknown client %q from %s", r.PostForm.Get("client_id"), r.RemoteAddr)
+		tokenError(w, "invalid_client")
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if r.PostForm.Get("refresh_token") != h.refreshToken {
+		log.Printf("Invalid refresh token from %s", r.RemoteAddr)
+		tokenError(w, "invalid_grant")
+		return
+	}
+
+	h.issued++
+	h.accessToken = fmt.Sprintf("access-%d", h.issued)
+	h.refreshToken = fmt.Sprintf("refresh-%d", h.issued)
+	log.Printf("Issued access token %d to %s", h.issued, r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"access_token":  h.accessToken,
+		"token_type":    "Bearer",
+		"expires_in":    h.ExpiresIn,
+		"refresh_token": h.refreshToken,
+	})
+}
+
+func tokenError(w http.ResponseWriter, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": code})
+}
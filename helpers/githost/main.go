@@ -16,6 +16,8 @@ var (
 	gitBinPath = flag.String("git-bin-path", "git", "Path to the git binary")
 	authUser   = flag.String("auth-user", "gopass", "Username for Basic Authentication (required if auth-pass is set)")
 	authPass   = flag.String("auth-pass", "pass", "Password for Basic Authentication (required if auth-user is set)")
+	oauthID    = flag.String("oauth-client-id", "", "Client ID accepted by the OAuth token endpoint at /oauth/token")
+	oauthToken = flag.String("oauth-refresh-token", "", "Initial refresh token accepted by the OAuth token endpoint (enables /oauth/token)")
 )
 
 func main() {
@@ -46,6 +48,11 @@ func main() {
 	finalHandler := githttp.BasicAuthMiddleware(githttp.GitHandler(absRepoRoot), *authUser, *authPass)
 	http.HandleFunc("/", finalHandler)
 
+	if *oauthToken != "" {
+		http.Handle("/oauth/token", githttp.NewTokenHandler(*oauthID, *oauthToken))
+		log.Printf("OAuth token endpoint enabled at /oauth/token")
+	}
+
 	log.Printf("Starting Git HTTP server on %s", *listenAddr)
 	err = http.ListenAndServe(*listenAddr, nil)
 	if err != nil {
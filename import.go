@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gopasspw/gopass/pkg/appdir"
+	"github.com/gopasspw/gopass/pkg/gopass/secrets"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	conflictSkip      = "skip"
+	conflictOverwrite = "overwrite"
+	conflictRename    = "rename"
+)
+
+// defaultImportSources returns the plaintext credential files that exist for the current user.
+func defaultImportSources() []string {
+	home := appdir.UserHome()
+	candidates := []string{filepath.Join(home, ".git-credentials")}
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		candidates = append(candidates, filepath.Join(xdg, "git", "credentials"))
+	} else {
+		candidates = append(candidates, filepath.Join(home, ".config", "git", "credentials"))
+	}
+	candidates = append(candidates, filepath.Join(home, ".netrc"))
+
+	sources := make([]string, 0, len(candidates))
+	for _, fn := range candidates {
+		if fi, err := os.Stat(fn); err == nil && fi.Mode().IsRegular() {
+			sources = append(sources, fn)
+		}
+	}
+
+	return sources
+}
+
+// parseCredentialStore parses the file format of git-credential-store, one URL per line.
+func parseCredentialStore(r io.Reader) ([]*gitCredentials, error) {
+	var creds []*gitCredentials
+
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		u, err := url.Parse(line)
+		if err != nil || u.Scheme == "" || u.Host == "" || u.User == nil {
+			return nil, fmt.Errorf("line %d: not a credential URL", n)
+		}
+		password, _ := u.User.Password()
+		creds = append(creds, &gitCredentials{
+			Protocol: u.Scheme,
+			Host:     u.Host,
+			Path:     strings.Trim(u.Path, "/"),
+			Username: u.User.Username(),
+			Password: password,
+		})
+	}
+
+	return creds, s.Err()
+}
+
+// parseNetrc parses the machine entries of a netrc file. Entries are assumed to be for https.
+// Macros, the default entry and entries without a password are skipped, they are described
+// in the second return value.
+func parseNetrc(r io.Reader) ([]*gitCredentials, []string, error) {
+	var (
+		creds   []*gitCredentials
+		skipped []string
+		cur     *gitCredentials
+		tokens  []string
+		inMacro bool
+	)
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := s.Text()
+		if inMacro {
+			// a macro definition ends with an empty line
+			inMacro = strings.TrimSpace(line) != ""
+
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		for _, field := range fields {
+			if field == "macdef" {
+				inMacro = true
+				skipped = append(skipped, "a macro definition")
+
+				break
+			}
+			tokens = append(tokens, field)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		key := tokens[i]
+		switch key {
+		case "machine":
+			if i+1 >= len(tokens) {
+				return nil, nil, fmt.Errorf("missing value for %q", key)
+			}
+			i++
+			cur = &gitCredentials{Protocol: "https", Host: tokens[i]}
+			creds = append(creds, cur)
+		case "default":
+			cur = nil
+			skipped = append(skipped, "the default entry")
+		case "login", "password", "account":
+			if i+1 >= len(tokens) {
+				return nil, nil, fmt.Errorf("missing value for %q", key)
+			}
+			i++
+			if cur == nil {
+				continue
+			}
+			switch key {
+			case "login":
+				cur.Username = tokens[i]
+			case "password":
+				cur.Password = tokens[i]
+			}
+		default:
+			return nil, nil, fmt.Errorf("unexpected token %q", key)
+		}
+	}
+
+	out := creds[:0]
+	for _, c := range creds {
+		if c.Password == "" {
+			skipped = append(skipped, fmt.Sprintf("machine %s without a password", c.Host))
+
+			continue
+		}
+		out = append(out, c)
+	}
+
+	return out, skipped, nil
+}
+
+// parseImportSource reads the credentials of a git-credential-store or netrc file.
+// It also describes the content of the file that is not imported, see parseNetrc.
+func parseImportSource(fn, format string) ([]*gitCredentials, []string, error) {
+	fh, err := os.Open(fn)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer fh.Close() //nolint:errcheck
+
+	if format == "" || format == "auto" {
+		format = "git-credentials"
+		if strings.Contains(filepath.Base(fn), "netrc") {
+			format = "netrc"
+		}
+	}
+
+	switch format {
+	case "git-credentials":
+		creds, err := parseCredentialStore(fh)
+
+		return creds, nil, err
+	case "netrc":
+		return parseNetrc(fh)
+	default:
+		return nil, nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// credentialString describes the credential as an URL without the password.
+func credentialString(cred *gitCredentials) string {
+	u := &url.URL{
+		Scheme: cred.Protocol,
+		Host:   cred.Host,
+		Path:   "/" + cred.Path,
+	}
+	if cred.Username != "" {
+		u.User = url.User(cred.Username)
+	}
+
+	return strings.TrimSuffix(u.String(), "/")
+}
+
+// Import imports plaintext git-credential-store and netrc files into gopass.
+func (s *gc) Import(ctx context.Context, cmd *cli.Command) error {
+	conflict := cmd.String("conflict")
+	switch conflict {
+	case conflictSkip, conflictOverwrite, conflictRename:
+	default:
+		return fmt.Errorf("unknown conflict policy %q, use %s, %s or %s", conflict, conflictSkip, conflictOverwrite, conflictRename)
+	}
+	dryRun := cmd.Bool("dry-run")
+
+	sources := cmd.Args().Slice()
+	if len(sources) < 1 {
+		sources = defaultImportSources()
+	}
+	if len(sources) < 1 {
+		return fmt.Errorf("no credential files found, please specify them")
+	}
+
+	store := targetStore(cmd)
+	for _, fn := range sources {
+		creds, skipped, err := parseImportSource(fn, cmd.String("format"))
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", fn, err)
+		}
+
+		written := 0
+		for _, cred := range creds {
+			ok, err := s.importCredential(ctx, cmd, store, cred, conflict, dryRun)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "gopass error: failed to import %s: %s\n", credentialString(cred), err)
+
+				continue
+			}
+			if ok {
+				written++
+			}
+		}
+
+		if dryRun || !cmd.Bool("shred") {
+			continue
+		}
+		// the plaintext file is the only copy of everything that was not written to gopass
+		if len(skipped) > 0 {
+			fmt.Fprintf(os.Stderr, "gopass warning: not removing %s because it holds content that is not imported: %s\n", fn, strings.Join(skipped, ", "))
+
+			continue
+		}
+		if written < len(creds) {
+			fmt.Fprintf(os.Stderr, "gopass warning: not removing %s because %d of %d entries were not imported\n", fn, len(creds)-written, len(creds))
+
+			continue
+		}
+		if err := shred(fn); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", fn, err)
+		}
+		fmt.Fprintf(Stdout, "Removed %s\n", fn)
+	}
+
+	return nil
+}
+
+// importCredential writes a single credential to the store. It returns true if it was written.
+func (s *gc) importCredential(ctx context.Context, cmd *cli.Command, store string, cred *gitCredentials, conflict string, dryRun bool) (bool, error) {
+	path, err := s.composePath(ctx, cmd, store, cred)
+	if err != nil {
+		return false, err
+	}
+
+	action := "import"
+	if _, err := s.gp.Get(ctx, path, "latest"); err == nil {
+		switch conflict {
+		case conflictSkip:
+			fmt.Fprintf(Stdout, "skip %s: %s already exists\n", credentialString(cred), path)
+
+			return false, nil
+		case conflictOverwrite:
+			action = "overwrite"
+		case conflictRename:
+			path = s.freePath(ctx, path)
+		}
+	}
+
+	if dryRun {
+		fmt.Fprintf(Stdout, "would %s %s -> %s\n", action, credentialString(cred), path)
+
+		return false, nil
+	}
+
+	secret := secrets.New()
+	secret.SetPassword(cred.Password)
+	if cred.Username != "" {
+		_ = secret.Set("login", cred.Username)
+	}
+	setOrigin(secret, cred, false)
+	_ = secret.Set("stored_at", time.Now().UTC().Format(time.RFC3339))
+
+	if err := s.gp.Set(ctx, path, secret); err != nil {
+		return false, err
+	}
+	s.updatePathIndex(ctx, cred, []string{path}, nil)
+	s.forgetMisses(cred.Host)
+	fmt.Fprintf(Stdout, "%s %s -> %s\n", action, credentialString(cred), path)
+
+	return true, nil
+}
+
+// freePath returns the first unused variant of path.
+func (s *gc) freePath(ctx context.Context, path string) string {
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s-%d", path, i)
+		if _, err := s.gp.Get(ctx, candidate, "latest"); err != nil {
+			return candidate
+		}
+	}
+}
+
+// shred overwrites the file with random data before removing it.
+func shred(fn string) error {
+	fi, err := os.Stat(fn)
+	if err != nil {
+		return err
+	}
+
+	fh, err := os.OpenFile(fn, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := io.CopyN(fh, rand.Reader, fi.Size()); err != nil {
+		_ = fh.Close()
+
+		return err
+	}
+	if err := fh.Sync(); err != nil {
+		_ = fh.Close()
+
+		return err
+	}
+	if err := fh.Close(); err != nil {
+		return err
+	}
+
+	return os.Remove(fn)
+}
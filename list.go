@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	expiryNone    = "none"
+	expiryValid   = "valid"
+	expiryExpired = "expired"
+)
+
+// listedCredential is the JSON representation of a listed credential. It never holds the password.
+type listedCredential struct {
+	Store     string     `json:"store"`
+	Protocol  string     `json:"protocol"`
+	Host      string     `json:"host"`
+	Path      string     `json:"path,omitempty"`
+	Username  string     `json:"username,omitempty"`
+	Wildcard  bool       `json:"wildcard,omitempty"`
+	Expiry    string     `json:"expiry"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Secret    string     `json:"secret"`
+}
+
+// expiryState returns the expiry state of the entry and when it expires.
+func expiryState(e *entry, now time.Time) (string, *time.Time) {
+	expiry, ok := expiresAt(e.Secret)
+	if !ok {
+		return expiryNone, nil
+	}
+	expiry = expiry.UTC()
+	if !expiry.After(now) {
+		return expiryExpired, &expiry
+	}
+
+	return expiryValid, &expiry
+}
+
+// List shows the credentials managed by gopass without their passwords.
+func (s *gc) List(ctx context.Context, cmd *cli.Command) error {
+	state := cmd.String("expiry")
+	switch state {
+	case "", expiryNone, expiryValid, expiryExpired:
+	default:
+		return fmt.Errorf("unknown expiry state %q, use %s, %s or %s", state, expiryNone, expiryValid, expiryExpired)
+	}
+
+	entries, err := s.filterEntries(ctx, cmd)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	out := make([]listedCredential, 0, len(entries))
+	for _, e := range entries {
+		st, expiry := expiryState(e, now)
+		if state != "" && st != state {
+			continue
+		}
+
+		out = append(out, listedCredential{
+			Store:     e.Store,
+			Protocol:  e.Cred.Protocol,
+			Host:      e.Cred.Host,
+			Path:      e.Cred.Path,
+			Username:  e.Cred.Username,
+			Wildcard:  isWildcard(e.Cred.Host),
+			Expiry:    st,
+			ExpiresAt: expiry,
+			Secret:    e.Name,
+		})
+	}
+
+	if cmd.Bool("json") {
+		enc := json.NewEncoder(Stdout)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(out)
+	}
+
+	tw := tabwriter.NewWriter(Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "STORE\tPROTOCOL\tHOST\tPATH\tUSERNAME\tEXPIRY\tSECRET")
+	for _, c := range out {
+		expiry := c.Expiry
+		if c.ExpiresAt != nil {
+			expiry += " (" + c.ExpiresAt.Format(time.RFC3339) + ")"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			storeName(c.Store), c.Protocol, c.Host, orDash(c.Path), orDash(c.Username), expiry, c.Secret)
+	}
+
+	return tw.Flush()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
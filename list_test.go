@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gopasspw/gopass/pkg/gopass/apimock"
+	"github.com/gopasspw/gopass/pkg/gopass/secrets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+func TestList(t *testing.T) { //nolint:paralleltest
+	ctx := t.Context()
+	act := &gc{
+		gp:  apimock.New(),
+		cfg: mapConfig{},
+	}
+
+	stdout := &bytes.Buffer{}
+	Stdout = stdout
+	defer func() {
+		Stdout = os.Stdout
+	}()
+
+	expired := time.Now().Add(-time.Hour).Truncate(time.Second).UTC()
+	for path, kvs := range map[string]map[string]string{
+		"git/github.com/bob":              {"login": "bob"},
+		"git/github.com/repo1/alice":      {"password_expiry_utc": strconv.FormatInt(expired.Unix(), 10)},
+		"work/git/git.corp_8443/carl":     {"protocol": "http"},
+		"websites/github.com/not-managed": {"url": "https://github.com"},
+	} {
+		sec := secrets.New()
+		sec.SetPassword("s3cret")
+		for k, v := range kvs {
+			require.NoError(t, sec.Set(k, v))
+		}
+		require.NoError(t, act.gp.Set(ctx, path, sec))
+	}
+
+	run := func(args ...string) {
+		t.Helper()
+
+		cmd := &cli.Command{
+			Name: "list",
+			Flags: []cli.Flag{
+				&cli.BoolFlag{Name: "json"},
+				&cli.StringFlag{Name: "host"},
+				&cli.StringFlag{Name: "store"},
+				&cli.StringFlag{Name: "expiry"},
+			},
+			Action: act.List,
+		}
+		stdout.Reset()
+		require.NoError(t, cmd.Run(ctx, append([]string{"list"}, args...)))
+	}
+
+	run()
+	assert.NotContains(t, stdout.String(), "s3cret")
+	assert.Contains(t, stdout.String(), "STORE")
+	assert.Contains(t, stdout.String(), "git/github.com/bob")
+	assert.Contains(t, stdout.String(), "git.corp:8443")
+	assert.NotContains(t, stdout.String(), "not-managed")
+
+	run("--json", "--expiry=expired")
+	assert.NotContains(t, stdout.String(), "s3cret")
+	var out []listedCredential
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &out))
+	assert.Equal(t, []listedCredential{{
+		Protocol:  "https",
+		Host:      "github.com",
+		Path:      "repo1",
+		Username:  "alice",
+		Expiry:    expiryExpired,
+		ExpiresAt: &expired,
+		Secret:    "git/github.com/repo1/alice",
+	}}, out)
+
+	run("--json", "--store=work", "--expiry=none")
+	out = nil
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &out))
+	require.Len(t, out, 1)
+	assert.Equal(t, "work", out[0].Store)
+	assert.Equal(t, "http", out[0].Protocol)
+
+	cmd := &cli.Command{
+		Name:   "list",
+		Flags:  []cli.Flag{&cli.StringFlag{Name: "expiry"}},
+		Action: act.List,
+	}
+	require.Error(t, cmd.Run(ctx, []string{"list", "--expiry=soon"}))
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	pathpkg "path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gopasspw/gopass/pkg/debug"
+	"github.com/gopasspw/gopass/pkg/gopass"
+	"github.com/urfave/cli/v3"
+)
+
+// expiresAt parses the password_expiry_utc field of a secret.
+// It returns false if the secret has no (valid) expiry.
+func expiresAt(secret gopass.Secret) (time.Time, bool) {
+	expiry, _ := secret.Get("password_expiry_utc")
+	if expiry == "" {
+		return time.Time{}, false
+	}
+
+	ts, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		debug.Log("invalid password_expiry_utc %q: %s", expiry, err)
+
+		return time.Time{}, false
+	}
+
+	return time.Unix(ts, 0), true
+}
+
+// isExpired returns true if the secret has an expiry that lies in the past.
+func isExpired(secret gopass.Secret, now time.Time) bool {
+	expiry, ok := expiresAt(secret)
+
+	return ok && !expiry.After(now)
+}
+
+// usable returns true if the secret can be handed out to git. Secrets marked invalid
+// after a rejection are never handed out, neither are secrets stored for https that
+// git requests over plain http. Expired access tokens are usable if they can be
+// refreshed, see renew. Expired secrets are left in place so that a later refresh
+// or a manual rotation can still use them.
+func (s *gc) usable(ctx context.Context, cred *gitCredentials, path string, secret gopass.Secret) bool {
+	if isInvalid(secret) {
+		since, _ := secret.Get("invalid_since")
+		debug.Log("skipping %q, rejected by the server since %s", path, since)
+		s.why("%q: rejected, marked invalid since %s", path, since)
+
+		return false
+	}
+
+	if s.refuseDowngrade(ctx, cred, path, storedProtocol(secret)) {
+		return false
+	}
+
+	if needsRefresh(secret, time.Now()) {
+		if tokenURL := s.oauthTokenURL(ctx, cred); tokenURL != "" && checkTokenURL(tokenURL) == nil {
+			// only the chosen entry is refreshed, see renew
+			s.why("%q: the access token would be refreshed", path)
+
+			return true
+		}
+		debug.Log("not refreshing %q, no %s.oauthTokenURL configured", path, configSection)
+	}
+
+	if !isExpired(secret, time.Now()) {
+		return true
+	}
+
+	expiry, _ := expiresAt(secret)
+	fmt.Fprintf(os.Stderr, "gopass warning: skipping expired credential %q (expired %s)\n", path, expiry.UTC().Format(time.RFC3339))
+	s.why("%q: rejected, expired %s", path, expiry.UTC().Format(time.RFC3339))
+
+	return false
+}
+
+// lookup finds the secret for the given credentials at path. It tries the exact path
+// first and then falls back to the usable entries below it, see choose.
+// It returns nil if no usable secret was found.
+func (s *gc) lookup(ctx context.Context, cred *gitCredentials, path string, list func(prefix string) ([]string, error)) (*match, error) {
+	secret, err := s.gp.Get(ctx, path, "latest")
+	switch {
+	case err != nil:
+		s.why("%q: rejected, no such secret", path)
+	case s.usable(ctx, cred, path, secret):
+		s.why("%q: accepted, exact match", path)
+
+		return &match{path: path, secret: secret}, nil
+	}
+
+	// if the looked up path is a directory with only one entry (e.g. one user per host), take the subentry instead
+	ls, err := list(path)
+	if err != nil {
+		return nil, fmt.Errorf("error: %w while listing the storage", err)
+	}
+
+	var candidates []*match
+	for _, entry := range filter(ls, path) {
+		if entry == path {
+			continue
+		}
+
+		secret, err := s.gp.Get(ctx, entry, "latest")
+		if err != nil {
+			debug.Log("failed to read %q: %s", entry, err)
+			s.why("%q: rejected, failed to read: %s", entry, err)
+
+			continue
+		}
+		if !s.usable(ctx, cred, entry, secret) {
+			continue
+		}
+
+		s.why("%q: candidate below %q", entry, path)
+		candidates = append(candidates, &match{path: entry, secret: secret})
+	}
+
+	if len(candidates) < 1 {
+		// no entry found, this is not an error
+		return nil, nil
+	}
+
+	m := s.choose(ctx, cred, candidates)
+	if m == nil {
+		reportAmbiguous(fmt.Sprintf("for %q", path), candidates)
+	}
+
+	return m, nil
+}
+
+// reportAmbiguous lists the entries competing for a request on stderr.
+func reportAmbiguous(what string, candidates []*match) {
+	paths := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		paths = append(paths, c.path)
+	}
+	fmt.Fprintf(os.Stderr, "gopass error: too many entries %s, mark one with \"default: true\" or set credential.username: %s\n",
+		what, strings.Join(paths, ", "))
+}
+
+// choose selects one of several matching entries. It prefers the entry marked
+// "default: true", then the one matching git's credential.username and finally
+// the most recently stored one. It returns nil if no rule decides.
+func (s *gc) choose(ctx context.Context, cred *gitCredentials, candidates []*match) *match {
+	if len(candidates) == 1 {
+		s.why("%q: accepted, only candidate", candidates[0].path)
+
+		return candidates[0]
+	}
+
+	defaults := slices.DeleteFunc(slices.Clone(candidates), func(m *match) bool {
+		v, _ := m.secret.Get("default")
+		ok, _ := strconv.ParseBool(v)
+
+		return !ok
+	})
+	switch len(defaults) {
+	case 0:
+	case 1:
+		s.why("%q: accepted, marked default: true", defaults[0].path)
+
+		return defaults[0]
+	default:
+		candidates = defaults
+	}
+
+	if username := s.config(ctx, "credential.username", cred); username != "" {
+		users := slices.DeleteFunc(slices.Clone(candidates), func(m *match) bool {
+			return secretUsername(m) != username
+		})
+		switch len(users) {
+		case 0:
+		case 1:
+			s.why("%q: accepted, matches credential.username %q", users[0].path, username)
+
+			return users[0]
+		default:
+			candidates = users
+		}
+	}
+
+	var (
+		latest   *match
+		latestTS time.Time
+		tie      bool
+	)
+	for _, m := range candidates {
+		ts, ok := storedAt(m.secret)
+		if !ok {
+			continue
+		}
+		switch {
+		case latest == nil || ts.After(latestTS):
+			latest, latestTS, tie = m, ts, false
+		case ts.Equal(latestTS):
+			tie = true
+		}
+	}
+	if tie || latest == nil {
+		s.why("no rule decides between the candidates")
+
+		return nil
+	}
+	s.why("%q: accepted, most recently stored at %s", latest.path, latestTS.Format(time.RFC3339))
+
+	return latest
+}
+
+// secretUsername returns the login of the secret or the last path component.
+func secretUsername(m *match) string {
+	if login, _ := m.secret.Get("login"); login != "" {
+		return login
+	}
+
+	return pathpkg.Base(m.path)
+}
+
+// storedAt returns when the secret was stored by this helper.
+func storedAt(secret gopass.Secret) (time.Time, bool) {
+	v, _ := secret.Get("stored_at")
+	if v == "" {
+		return time.Time{}, false
+	}
+
+	ts, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		debug.Log("invalid stored_at %q: %s", v, err)
+
+		return time.Time{}, false
+	}
+
+	return ts, true
+}
+
+// lookupChain returns the credentials to try in order: the full repository path,
+// each of its parent path segments, the host with and finally without the port.
+func lookupChain(cred *gitCredentials) []*gitCredentials {
+	chain := []*gitCredentials{cred}
+
+	path := strings.Trim(cred.Path, "/")
+	for path != "" {
+		idx := strings.LastIndex(path, "/")
+		if idx < 0 {
+			path = ""
+		} else {
+			path = path[:idx]
+		}
+
+		c := *cred
+		c.Path = path
+		chain = append(chain, &c)
+	}
+
+	if host, port := splitHostPort(cred.Host); port != "" {
+		c := *cred
+		c.Host = host
+		c.Path = ""
+		chain = append(chain, &c)
+	}
+
+	return chain
+}
+
+// match is a secret found for a credential request.
+type match struct {
+	store  string
+	path   string
+	secret gopass.Secret
+	// wildcard is the host pattern of a wildcard entry, e.g. *.review.corp
+	wildcard string
+}
+
+// find looks up the secret for the given credentials, see search. The access token
+// of the match is refreshed if it is expired or about to expire.
+// It returns nil if no usable secret was found.
+func (s *gc) find(ctx context.Context, cmd *cli.Command, cred *gitCredentials) (*match, error) {
+	cred = s.canonical(ctx, cred)
+	m, err := s.search(ctx, cmd, cred)
+	if err != nil || m == nil {
+		return m, err
+	}
+	if !s.renew(ctx, cred, m) {
+		return nil, nil
+	}
+
+	return m, nil
+}
+
+// renew refreshes the access token of the chosen entry if needed. It returns false if
+// the entry is expired and could not be refreshed. Resolve does not change any secrets.
+func (s *gc) renew(ctx context.Context, cred *gitCredentials, m *match) bool {
+	if s.explain != nil || !needsRefresh(m.secret, time.Now()) || s.oauthTokenURL(ctx, cred) == "" {
+		return true
+	}
+
+	if err := s.refresh(ctx, cred, m.path, m.secret); err != nil {
+		fmt.Fprintf(os.Stderr, "gopass warning: failed to refresh %q: %s\n", m.path, err)
+	}
+	if !isExpired(m.secret, time.Now()) {
+		return true
+	}
+
+	expiry, _ := expiresAt(m.secret)
+	fmt.Fprintf(os.Stderr, "gopass warning: skipping expired credential %q (expired %s)\n", m.path, expiry.UTC().Format(time.RFC3339))
+
+	return false
+}
+
+// search looks up the secret for the canonical credentials. It searches the stores in
+// priority order and follows the lookupChain in each of them. The first match wins.
+// Entries listing the host in their aliases field and then wildcard entries are only
+// considered if there is none.
+// It returns nil if no usable secret was found.
+func (s *gc) search(ctx context.Context, cmd *cli.Command, cred *gitCredentials) (*match, error) {
+	list := s.lister(ctx, cmd, cred)
+
+	stores := searchStores(cmd)
+	seen := make(map[string]bool, 4*len(stores))
+	for _, store := range stores {
+		for _, c := range lookupChain(cred) {
+			paths, err := s.candidatePaths(ctx, cmd, store, c)
+			if err != nil {
+				return nil, err
+			}
+			for _, path := range paths {
+				if seen[path] {
+					s.why("%q: skipped, already considered", path)
+
+					continue
+				}
+				seen[path] = true
+
+				debug.Log("looking up %q in store %s", path, storeName(store))
+				s.why("looking up %q in store %s", path, storeName(store))
+				m, err := s.lookup(ctx, cred, path, list)
+				if err != nil {
+					return nil, err
+				}
+				if m == nil {
+					continue
+				}
+				m.store = store
+
+				debug.Log("found %q in store %s", m.path, storeName(store))
+				if len(stores) > 1 {
+					fmt.Fprintf(os.Stderr, "gopass: using credential %q from store %s\n", m.path, storeName(store))
+				}
+
+				return m, nil
+			}
+		}
+	}
+
+	// an entry naming the host as an alias is more specific than a wildcard
+	if s.searchAliases(ctx, cred) {
+		s.why("searching the git tree for entries with %s in their aliases field", cred.Host)
+		m, err := s.findByAlias(ctx, cred, list)
+		if err != nil || m != nil {
+			return m, err
+		}
+	}
+
+	s.why("searching the git tree for wildcard entries matching %s", cred.Host)
+	m, err := s.findByWildcard(ctx, cmd, cred, list, true)
+	if err != nil || m != nil {
+		return m, err
+	}
+
+	if s.searchURLs(ctx, cmd, cred) {
+		s.why("searching all secrets by their url or host field")
+
+		return s.findByURL(ctx, cred, list)
+	}
+
+	return nil, nil
+}
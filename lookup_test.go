@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gopasspw/gopass/pkg/ctxutil"
+	"github.com/gopasspw/gopass/pkg/gopass/apimock"
+	"github.com/gopasspw/gopass/pkg/gopass/secrets"
+	"github.com/gopasspw/gopass/pkg/termio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsExpired(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1000, 0)
+	for _, tc := range []struct {
+		expiry string
+		want   bool
+	}{
+		{expiry: "", want: false},
+		{expiry: "invalid", want: false},
+		{expiry: "999", want: true},
+		{expiry: "1000", want: true},
+		{expiry: "1001", want: false},
+	} {
+		sec := secrets.New()
+		if tc.expiry != "" {
+			require.NoError(t, sec.Set("password_expiry_utc", tc.expiry))
+		}
+		assert.Equal(t, tc.want, isExpired(sec, now), tc.expiry)
+	}
+}
+
+func Test_lookupChain(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name string
+		cred *gitCredentials
+		want [][2]string
+	}{
+		{
+			name: "host only",
+			cred: &gitCredentials{Host: "example.com"},
+			want: [][2]string{{"example.com", ""}},
+		},
+		{
+			name: "nested repository path",
+			cred: &gitCredentials{Host: "example.com", Path: "group/sub/repo.git"},
+			want: [][2]string{
+				{"example.com", "group/sub/repo.git"},
+				{"example.com", "group/sub"},
+				{"example.com", "group"},
+				{"example.com", ""},
+			},
+		},
+		{
+			name: "with port",
+			cred: &gitCredentials{Host: "example.com:8443", Path: "org/repo"},
+			want: [][2]string{
+				{"example.com:8443", "org/repo"},
+				{"example.com:8443", "org"},
+				{"example.com:8443", ""},
+				{"example.com", ""},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := make([][2]string, 0, len(tc.want))
+			for _, c := range lookupChain(tc.cred) {
+				got = append(got, [2]string{c.Host, c.Path})
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestGitCredentialHelperFallback(t *testing.T) { //nolint:paralleltest
+	ctx := t.Context()
+	act := &gc{
+		gp:       apimock.New(),
+		cfg:      mapConfig{},
+		cacheDir: t.TempDir(),
+	}
+
+	stdout := &bytes.Buffer{}
+	Stdout = stdout
+	defer func() {
+		Stdout = os.Stdout
+		termio.Stdin = os.Stdin
+	}()
+
+	cmd := testCmd(t, ctx, nil)
+	ctx = ctxutil.WithStdin(ctx, true)
+
+	for path, pw := range map[string]string{
+		"git/example.com/bob":              "host",
+		"git/example.com/org/bob":          "org",
+		"git/example.com/org_special/bob":  "special",
+		"git/example.com_8443/other/bob":   "port",
+		"git/example.com/unrelated_x/carl": "unrelated",
+	} {
+		sec := secrets.New()
+		sec.SetPassword(pw)
+		require.NoError(t, sec.Set("login", "bob"))
+		require.NoError(t, act.gp.Set(ctx, path, sec))
+	}
+
+	for _, tc := range []struct {
+		in   string
+		want string
+	}{
+		{in: "host=example.com\nusername=bob\npath=org/special\n", want: "special"},
+		{in: "host=example.com\nusername=bob\npath=org/repo\n", want: "org"},
+		{in: "host=example.com\nusername=bob\npath=org\n", want: "org"},
+		{in: "host=example.com\nusername=bob\npath=other/repo\n", want: "host"},
+		{in: "host=example.com\nusername=bob\n", want: "host"},
+		{in: "host=example.com\npath=foo/bar\n", want: "host"},
+		{in: "host=example.com:8443\nusername=bob\npath=other/repo\n", want: "port"},
+		{in: "host=example.com:8443\nusername=bob\npath=org/repo\n", want: "host"},
+	} {
+		termio.Stdin = strings.NewReader("protocol=https\n" + tc.in)
+		require.NoError(t, act.Get(ctx, cmd))
+		read, err := parseGitCredentials(stdout)
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, read.Password, tc.in)
+		stdout.Reset()
+	}
+
+	// store changes the entry get resolved to instead of creating a copy
+	termio.Stdin = strings.NewReader("protocol=https\nhost=example.com\nusername=bob\npath=org/repo\npassword=rotated\n")
+	require.NoError(t, act.Store(ctx, cmd))
+	sec, err := act.gp.Get(ctx, "git/example.com/org/bob", "latest")
+	require.NoError(t, err)
+	assert.Equal(t, "rotated", sec.Password())
+
+	// an entry serving other repositories is kept when one of them rejects it
+	termio.Stdin = strings.NewReader("protocol=https\nhost=example.com\nusername=bob\npath=org/repo\npassword=rotated\n")
+	require.NoError(t, act.Erase(ctx, cmd))
+	_, err = act.gp.Get(ctx, "git/example.com/org/bob", "latest")
+	require.NoError(t, err)
+
+	termio.Stdin = strings.NewReader("protocol=https\nhost=example.com\nusername=bob\npath=org/special\npassword=special\n")
+	require.NoError(t, act.Erase(ctx, cmd))
+	_, err = act.gp.Get(ctx, "git/example.com/org_special/bob", "latest")
+	require.Error(t, err)
+
+	// the full path is only used if there is no entry on the lookup chain
+	termio.Stdin = strings.NewReader("protocol=https\nhost=example.org\nusername=bob\npath=org/repo\npassword=new\n")
+	require.NoError(t, act.Store(ctx, cmd))
+	_, err = act.gp.Get(ctx, "git/example.org/org_repo/bob", "latest")
+	require.NoError(t, err)
+
+	ls, err := act.gp.List(ctx)
+	require.NoError(t, err)
+	assert.NotContains(t, ls, "git/example.com/org_repo/bob")
+}
+
+func TestChoose(t *testing.T) {
+	t.Parallel()
+
+	entry := func(path string, kvs ...string) *match {
+		sec := secrets.New()
+		sec.SetPassword(path)
+		for i := 0; i+1 < len(kvs); i += 2 {
+			require.NoError(t, sec.Set(kvs[i], kvs[i+1]))
+		}
+
+		return &match{path: path, secret: sec}
+	}
+
+	for _, tc := range []struct {
+		name       string
+		username   string
+		candidates []*match
+		want       string
+	}{
+		{
+			name:       "single entry",
+			candidates: []*match{entry("git/h/a")},
+			want:       "git/h/a",
+		},
+		{
+			name: "default entry",
+			candidates: []*match{
+				entry("git/h/a", "stored_at", "2026-01-02T00:00:00Z"),
+				entry("git/h/b", "default", "true"),
+			},
+			username: "a",
+			want:     "git/h/b",
+		},
+		{
+			name: "credential.username",
+			candidates: []*match{
+				entry("git/h/a", "stored_at", "2026-01-02T00:00:00Z"),
+				entry("git/h/b", "login", "bob"),
+			},
+			username: "bob",
+			want:     "git/h/b",
+		},
+		{
+			name: "credential.username among several defaults",
+			candidates: []*match{
+				entry("git/h/a", "default", "true"),
+				entry("git/h/b", "default", "true"),
+				entry("git/h/c"),
+			},
+			username: "b",
+			want:     "git/h/b",
+		},
+		{
+			name: "most recently stored",
+			candidates: []*match{
+				entry("git/h/a", "stored_at", "2026-01-01T00:00:00Z"),
+				entry("git/h/b", "stored_at", "2026-01-02T00:00:00Z"),
+				entry("git/h/c"),
+			},
+			username: "nobody",
+			want:     "git/h/b",
+		},
+		{
+			name: "undecided",
+			candidates: []*match{
+				entry("git/h/a"),
+				entry("git/h/b"),
+			},
+			want: "",
+		},
+		{
+			name: "tie",
+			candidates: []*match{
+				entry("git/h/a", "stored_at", "2026-01-01T00:00:00Z"),
+				entry("git/h/b", "stored_at", "2026-01-01T00:00:00Z"),
+			},
+			want: "",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			act := &gc{cfg: mapConfig{"credential.username": tc.username}}
+			got := act.choose(t.Context(), &gitCredentials{}, tc.candidates)
+			if tc.want == "" {
+				assert.Nil(t, got)
+
+				return
+			}
+			require.NotNil(t, got)
+			assert.Equal(t, tc.want, got.path)
+		})
+	}
+}
@@ -44,15 +44,7 @@ func main() {
 		ctx = ctxutil.WithStdin(ctx, true)
 	}
 
-	gp, err := api.New(ctx)
-	if err != nil {
-		fmt.Printf("Failed to initialize gopass API: %s\n", err)
-		os.Exit(1)
-	}
-
-	gc := &gc{
-		gp: gp,
-	}
+	gc := &gc{}
 
 	app := &cli.Command{
 		Name:    name,
@@ -61,10 +53,38 @@ func main() {
 		Description: "This command allows you to cache your git-credentials with gopass." +
 			"Activate by using `git config --global credential.helper gopass`",
 		EnableShellCompletion: true,
+		Before: func(ctx context.Context, cmd *cli.Command) (context.Context, error) {
+			switch cmd.Args().First() {
+			case "doctor", "get", "cache-daemon", "exit":
+				// these initialize the gopass API themselves if they need it at all
+				return ctx, nil
+			}
+
+			gp, err := api.New(ctx)
+			if err != nil {
+				fmt.Printf("Failed to initialize gopass API: %s\n", err)
+				os.Exit(1)
+			}
+			gc.gp = gp
+
+			return ctx, nil
+		},
 		Flags: []cli.Flag{
 			&cli.StringFlag{
 				Name:  "store",
-				Usage: "First part of path to find the secret.",
+				Usage: "First part of path to find the secret. A comma separated list is searched in order, use \"/\" for the root store.",
+			},
+			&cli.StringFlag{
+				Name:  "target-store",
+				Usage: "Store new credentials are written to. Defaults to the first --store.",
+			},
+			&cli.StringFlag{
+				Name:  "path-template",
+				Usage: "Template for the secret path, e.g. \"{{.Store}}/creds/{{.Protocol}}/{{.Host}}/{{.User}}\".",
+			},
+			&cli.BoolFlag{
+				Name:  "search-urls",
+				Usage: "Also match secrets anywhere in the store by their url or host field.",
 			},
 		},
 		Commands: []*cli.Command{
@@ -105,10 +125,173 @@ func main() {
 					},
 					&cli.StringFlag{
 						Name:  "store",
-						Usage: "First part of path to find the secret.",
+						Usage: "First part of path to find the secret. A comma separated list is searched in order, use \"/\" for the root store.",
+					},
+					&cli.StringFlag{
+						Name:  "target-store",
+						Usage: "Store new credentials are written to. Defaults to the first --store.",
+					},
+					&cli.StringFlag{
+						Name:  "path-template",
+						Usage: "Template for the secret path, e.g. \"{{.Store}}/creds/{{.Protocol}}/{{.Host}}/{{.User}}\".",
+					},
+					&cli.BoolFlag{
+						Name:  "search-urls",
+						Usage: "Also match secrets anywhere in the store by their url or host field.",
+					},
+				},
+			},
+			{
+				Name:      "import",
+				Usage:     "Import git-credential-store and netrc files into gopass",
+				ArgsUsage: "[file...]",
+				Description: "" +
+					"This command imports plaintext credentials from git-credential-store files " +
+					"(~/.git-credentials) and netrc files (~/.netrc) into gopass. " +
+					"Without arguments all of these files that exist are imported.",
+				Action: gc.Import,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "Format of the files: auto, git-credentials or netrc",
+						Value: "auto",
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Only list what would be imported",
+					},
+					&cli.StringFlag{
+						Name:  "conflict",
+						Usage: "What to do with existing entries: skip, overwrite or rename",
+						Value: conflictSkip,
+					},
+					&cli.BoolFlag{
+						Name:  "shred",
+						Usage: "Overwrite and remove the plaintext files after a successful import",
+					},
+				},
+			},
+			{
+				Name:  "export",
+				Usage: "Export the credentials managed by gopass",
+				Description: "" +
+					"This command exports the credentials in the git/ tree of your stores " +
+					"in the git-credential-store, netrc or JSON format.",
+				Action: gc.Export,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "Output format: git-credentials, netrc or json",
+						Value: "git-credentials",
+					},
+					&cli.StringFlag{
+						Name:  "host",
+						Usage: "Only export credentials for this host",
+					},
+					&cli.StringFlag{
+						Name:  "store",
+						Usage: "Only export credentials from these stores (comma separated, \"/\" for the root store)",
+					},
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Usage:   "Write to this file (mode 0600) instead of stdout",
+					},
+				},
+			},
+			{
+				Name:    "list",
+				Aliases: []string{"ls"},
+				Usage:   "List the credentials managed by gopass",
+				Description: "" +
+					"This command lists the credentials in the git/ tree of your stores. " +
+					"Passwords are never shown.",
+				Action: gc.List,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "Print JSON instead of a table",
+					},
+					&cli.StringFlag{
+						Name:  "host",
+						Usage: "Only list credentials for this host",
+					},
+					&cli.StringFlag{
+						Name:  "store",
+						Usage: "Only list credentials from these stores (comma separated, \"/\" for the root store)",
+					},
+					&cli.StringFlag{
+						Name:  "expiry",
+						Usage: "Only list credentials with this expiry state: none, valid or expired",
+					},
+				},
+			},
+			{
+				Name:      "restore",
+				Usage:     "Restore credentials erased after the server rejected them",
+				ArgsUsage: "[path...]",
+				Description: "" +
+					"Without arguments this command lists the credentials that were moved to the trash or " +
+					"marked invalid because the server rejected them. With arguments it restores them.",
+				Action: gc.Restore,
+			},
+			{
+				Name:  "migrate",
+				Usage: "Rename credentials stored in the layout of earlier versions",
+				Description: "" +
+					"Earlier versions encoded hosts, ports, paths and usernames lossily in the secret names. " +
+					"This command renames the entries in the git/ tree to the reversible encoding. " +
+					"Entries whose new name is already taken are reported and left alone.",
+				Action: gc.Migrate,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Only list what would be renamed and the collisions",
 					},
 				},
 			},
+			{
+				Name:      "resolve",
+				Usage:     "Explain which credential git would get for an URL",
+				ArgsUsage: "<url>",
+				Description: "" +
+					"This command runs the same lookup as git-credential get for the URL and prints every " +
+					"path considered and why it was accepted or rejected. It also shows the paths store and " +
+					"erase would use. Passwords are never shown.",
+				Action: gc.Resolve,
+			},
+			{
+				Name:  "cache-daemon",
+				Usage: "Hold decrypted credentials in memory for a limited time",
+				Description: "" +
+					"This command runs the credential cache daemon in the foreground. It is started " +
+					"automatically if credential-gopass.cacheTimeout is set.",
+				Action: gc.CacheDaemon,
+				Flags: []cli.Flag{
+					&cli.DurationFlag{
+						Name:  "timeout",
+						Usage: "How long credentials are cached",
+						Value: defaultCacheTimeout,
+					},
+					&cli.StringFlag{
+						Name:  "socket",
+						Usage: "Unix socket to listen on",
+					},
+				},
+			},
+			{
+				Name:   "exit",
+				Usage:  "Stop the credential cache daemon",
+				Action: gc.CacheExit,
+			},
+			{
+				Name:  "doctor",
+				Usage: "Diagnose the credential helper setup",
+				Description: "" +
+					"This command checks the credential.helper chain, the useHttpPath settings, " +
+					"the gopass API, the configured stores and non-interactive decryption and suggests fixes.",
+				Action: gc.Doctor,
+			},
 			{
 				Name: "version",
 				Action: func(ctx context.Context, cmd *cli.Command) error {
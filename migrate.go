@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+)
+
+// migration renames an entry from the lossy layout of earlier versions.
+type migration struct {
+	From string
+	To   string
+}
+
+// migrationTarget returns the name of the entry in the current layout. The URL rebuilt
+// from the url, protocol, port and login fields is preferred over the one decoded from
+// the name since the legacy name may be a valid name in the current layout as well,
+// e.g. git/1_8080/alice for https://[::1]:8080. It returns false if the entry does not
+// use the default layout or its URL can not be recovered.
+func migrationTarget(e *entry) (string, bool) {
+	_, rest, _ := splitGitTree(e.Name)
+
+	for _, c := range []*gitCredentials{e.Cred, parseName(rest)} {
+		path, err := renderPath(defaultPathTemplate, e.Store, c)
+		if err != nil {
+			continue
+		}
+		path = strings.TrimSuffix(path, "/")
+		if path == e.Name {
+			// already migrated
+			return path, true
+		}
+		if legacy, err := renderLegacyPath(defaultPathTemplate, e.Store, c); err == nil && strings.TrimSuffix(legacy, "/") == e.Name {
+			return path, true
+		}
+	}
+
+	return "", false
+}
+
+// planMigrations returns the entries to rename. Entries whose new name is taken by another
+// entry are reported on stdout and left alone. It also returns the number of collisions.
+func planMigrations(entries []*entry) ([]migration, int) {
+	existing := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		existing[e.Name] = true
+	}
+
+	var (
+		out        []migration
+		collisions int
+	)
+	claimed := make(map[string]string, len(entries))
+	for _, e := range entries {
+		if isWildcard(e.Cred.Host) {
+			// written by hand, the pattern is kept as is
+			continue
+		}
+		to, ok := migrationTarget(e)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "gopass warning: not migrating %q, its URL can not be recovered from the name\n", e.Name)
+
+			continue
+		}
+		if to == e.Name {
+			continue
+		}
+
+		switch {
+		case existing[to]:
+			fmt.Fprintf(Stdout, "collision: %s -> %s, %s already exists\n", e.Name, to, to)
+			collisions++
+		case claimed[to] != "":
+			fmt.Fprintf(Stdout, "collision: %s -> %s, %s is renamed to it as well\n", e.Name, to, claimed[to])
+			collisions++
+		default:
+			claimed[to] = e.Name
+			out = append(out, migration{From: e.Name, To: to})
+		}
+	}
+
+	return out, collisions
+}
+
+// Migrate renames the entries in the git tree that still use the lossy layout of
+// earlier versions, e.g. git/example.com/John_Doe with the login "John Doe" becomes
+// git/example.com/John%20Doe.
+func (s *gc) Migrate(ctx context.Context, cmd *cli.Command) error {
+	entries, err := s.entries(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	plan, collisions := planMigrations(entries)
+	for _, m := range plan {
+		if cmd.Bool("dry-run") {
+			fmt.Fprintf(Stdout, "would rename %s -> %s\n", m.From, m.To)
+
+			continue
+		}
+
+		if err := s.gp.Rename(ctx, m.From, m.To); err != nil {
+			return fmt.Errorf("failed to rename %q: %w", m.From, err)
+		}
+		s.updatePathIndex(ctx, &gitCredentials{}, []string{m.To}, []string{m.From})
+		fmt.Fprintf(Stdout, "rename %s -> %s\n", m.From, m.To)
+	}
+
+	if len(plan) == 0 && collisions == 0 {
+		fmt.Fprintln(Stdout, "Nothing to migrate.")
+	}
+	if collisions > 0 {
+		return fmt.Errorf("found %d collision(s), rename or remove these entries by hand", collisions)
+	}
+
+	return nil
+}
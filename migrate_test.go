@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gopasspw/gopass/pkg/ctxutil"
+	"github.com/gopasspw/gopass/pkg/gopass/apimock"
+	"github.com/gopasspw/gopass/pkg/gopass/secrets"
+	"github.com/gopasspw/gopass/pkg/termio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+func TestMigrate(t *testing.T) { //nolint:paralleltest
+	ctx := t.Context()
+	act := &gc{
+		gp:       apimock.New(),
+		cfg:      mapConfig{},
+		cacheDir: t.TempDir(),
+	}
+
+	for name, fields := range map[string]map[string]string{
+		// the login holds the original username
+		"git/example.com/John_Doe": {"login": "John Doe"},
+		// the url holds the original host
+		"git/1_8080/alice": {"url": "https://[::1]:8080/"},
+		// already in the current layout
+		"git/example.com_8080/org_repo/bob": {},
+		"work/git/example.com/carl":         {},
+		// both become git/example.org/a%5Fb
+		"git/example.org/a_b":   {},
+		"git/example.org/a%5Fb": {},
+		// added by hand in a nested layout
+		"git/example.net/org/repo/dave": {},
+	} {
+		sec := secrets.New()
+		sec.SetPassword("s3cret")
+		for k, v := range fields {
+			require.NoError(t, sec.Set(k, v))
+		}
+		require.NoError(t, act.gp.Set(ctx, name, sec))
+	}
+
+	stdout := &bytes.Buffer{}
+	Stdout = stdout
+	defer func() {
+		Stdout = os.Stdout
+		termio.Stdin = os.Stdin
+	}()
+
+	run := func(args ...string) error {
+		t.Helper()
+
+		cmd := &cli.Command{
+			Name: "migrate",
+			Flags: []cli.Flag{
+				&cli.BoolFlag{Name: "dry-run"},
+			},
+			Action: act.Migrate,
+		}
+		stdout.Reset()
+
+		return cmd.Run(ctx, append([]string{"migrate"}, args...))
+	}
+
+	require.Error(t, run("--dry-run"))
+	out := stdout.String()
+	assert.Contains(t, out, "would rename git/example.com/John_Doe -> git/example.com/John%20Doe\n")
+	assert.Contains(t, out, "would rename git/1_8080/alice -> git/%3A%3A1_8080/alice\n")
+	assert.Contains(t, out, "collision: git/example.org/a_b -> git/example.org/a%5Fb, git/example.org/a%5Fb already exists\n")
+	assert.NotContains(t, out, "bob")
+	assert.NotContains(t, out, "carl")
+	assert.NotContains(t, out, "dave")
+	_, err := act.gp.Get(ctx, "git/example.com/John_Doe", "latest")
+	require.NoError(t, err)
+
+	require.Error(t, run())
+	for _, name := range []string{"git/example.com/John%20Doe", "git/%3A%3A1_8080/alice", "git/example.org/a_b"} {
+		_, err := act.gp.Get(ctx, name, "latest")
+		require.NoError(t, err, name)
+	}
+	_, err = act.gp.Get(ctx, "git/example.com/John_Doe", "latest")
+	require.Error(t, err)
+
+	// the migrated entry is found under its new name
+	ctx = ctxutil.WithStdin(ctx, true)
+	stdout.Reset()
+	termio.Stdin = strings.NewReader("protocol=https\nhost=[::1]:8080\nusername=alice\n")
+	require.NoError(t, act.Get(ctx, testCmd(t, ctx, nil)))
+	read, err := parseGitCredentials(stdout)
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", read.Password)
+}
+
+func TestGitCredentialHelperLegacyPath(t *testing.T) { //nolint:paralleltest
+	ctx := t.Context()
+	act := &gc{
+		gp:       apimock.New(),
+		cfg:      mapConfig{},
+		cacheDir: t.TempDir(),
+	}
+
+	sec := secrets.New()
+	sec.SetPassword("s3cret")
+	require.NoError(t, act.gp.Set(ctx, "git/example.com/John_Doe", sec))
+
+	stdout := &bytes.Buffer{}
+	Stdout = stdout
+	defer func() {
+		Stdout = os.Stdout
+		termio.Stdin = os.Stdin
+	}()
+
+	// entries that were not migrated yet are still found
+	ctx = ctxutil.WithStdin(ctx, true)
+	termio.Stdin = strings.NewReader("protocol=https\nhost=example.com\nusername=John Doe\n")
+	require.NoError(t, act.Get(ctx, testCmd(t, ctx, nil)))
+	read, err := parseGitCredentials(stdout)
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", read.Password)
+
+	// and updated in place instead of creating a copy under the new name
+	for _, in := range []struct {
+		path string
+		req  string
+	}{
+		{"git/example.com/John_Doe", "username=John Doe\npassword=n3w\n"},
+		{"git/example.com/org_repo.git/bob", "path=org/repo.git\nusername=bob\npassword=n3w\n"},
+	} {
+		sec := secrets.New()
+		sec.SetPassword("s3cret")
+		require.NoError(t, act.gp.Set(ctx, in.path, sec))
+
+		termio.Stdin = strings.NewReader("protocol=https\nhost=example.com\n" + in.req)
+		require.NoError(t, act.Store(ctx, testCmd(t, ctx, nil)))
+		stored, err := act.gp.Get(ctx, in.path, "latest")
+		require.NoError(t, err, in.path)
+		assert.Equal(t, "n3w", stored.Password(), in.path)
+	}
+	ls, err := act.gp.List(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"git/example.com/John_Doe", "git/example.com/org_repo.git/bob"}, ls)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gopasspw/gopass/pkg/debug"
+	"github.com/urfave/cli/v3"
+)
+
+type missEntry struct {
+	Host string    `json:"host"`
+	At   time.Time `json:"at"`
+}
+
+// misses remembers requests no credential was found for, keyed by cacheKey.
+// It never holds any secrets.
+type misses map[string]missEntry
+
+func loadMisses(fn string) misses {
+	m := misses{}
+
+	buf, err := os.ReadFile(fn)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			debug.Log("failed to read misses %s: %s", fn, err)
+		}
+
+		return m
+	}
+	if err := json.Unmarshal(buf, &m); err != nil {
+		debug.Log("discarding invalid misses %s: %s", fn, err)
+
+		return misses{}
+	}
+
+	return m
+}
+
+func (m misses) save(fn string) error {
+	buf, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fn), 0o700); err != nil {
+		return err
+	}
+
+	return os.WriteFile(fn, buf, 0o600)
+}
+
+// missTTL returns how long lookups without a result are remembered, see
+// credential-gopass.missTTL. Zero disables the negative cache.
+func (s *gc) missTTL(ctx context.Context, cred *gitCredentials) time.Duration {
+	return s.configDuration(ctx, configSection+".missTTL", cred)
+}
+
+// knownMiss returns true if no credential was found for the same request recently.
+func (s *gc) knownMiss(ctx context.Context, cmd *cli.Command, cred *gitCredentials) bool {
+	ttl := s.missTTL(ctx, cred)
+	if ttl <= 0 {
+		return false
+	}
+
+	e, found := loadMisses(s.cachePath("misses.json"))[cacheKey(cmd, cred)]
+
+	return found && time.Since(e.At) < ttl
+}
+
+// recordMiss remembers that no credential was found for the request.
+func (s *gc) recordMiss(ctx context.Context, cmd *cli.Command, cred *gitCredentials) {
+	ttl := s.missTTL(ctx, cred)
+	if ttl <= 0 {
+		return
+	}
+
+	fn := s.cachePath("misses.json")
+	m := loadMisses(fn)
+	now := time.Now()
+	for key, e := range m {
+		if now.Sub(e.At) >= ttl {
+			delete(m, key)
+		}
+	}
+	m[cacheKey(cmd, cred)] = missEntry{Host: normalizeHost(cred.Protocol, cred.Host), At: now}
+	if err := m.save(fn); err != nil {
+		debug.Log("failed to write misses %s: %s", fn, err)
+	}
+}
+
+// forgetMisses drops the remembered misses for host on any port, or all of them if host
+// is empty. A new entry may serve any path and port of the host through the lookup chain.
+func (s *gc) forgetMisses(host string) {
+	hostname, _ := splitHostPort(normalizeHost("", host))
+	fn := s.cachePath("misses.json")
+	m := loadMisses(fn)
+	if len(m) < 1 {
+		return
+	}
+
+	changed := false
+	for key, e := range m {
+		if h, _ := splitHostPort(e.Host); host == "" || h == hostname {
+			delete(m, key)
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+	if err := m.save(fn); err != nil {
+		debug.Log("failed to write misses %s: %s", fn, err)
+	}
+}
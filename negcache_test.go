@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gopasspw/gopass/pkg/ctxutil"
+	"github.com/gopasspw/gopass/pkg/gopass"
+	"github.com/gopasspw/gopass/pkg/gopass/apimock"
+	"github.com/gopasspw/gopass/pkg/gopass/secrets"
+	"github.com/gopasspw/gopass/pkg/termio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitCredentialHelperMissCache(t *testing.T) { //nolint:paralleltest
+	ctx := t.Context()
+	gp := apimock.New()
+	act := &gc{
+		cfg:      mapConfig{"credential-gopass.missTTL": "1m"},
+		cacheDir: t.TempDir(),
+	}
+
+	inits := 0
+	oldAPI := newAPI
+	newAPI = func(context.Context) (gopass.Store, error) {
+		inits++
+
+		return gp, nil
+	}
+	defer func() {
+		newAPI = oldAPI
+	}()
+
+	stdout := &bytes.Buffer{}
+	Stdout = stdout
+	defer func() {
+		Stdout = os.Stdout
+		termio.Stdin = os.Stdin
+	}()
+
+	ctx = ctxutil.WithStdin(ctx, true)
+	cmd := testCmd(t, ctx, nil)
+	s := "protocol=https\nhost=mirror.example.com:8443\npath=org/repo.git\n"
+
+	termio.Stdin = strings.NewReader(s)
+	require.NoError(t, act.Get(ctx, cmd))
+	assert.Empty(t, stdout.String())
+	assert.Equal(t, 1, inits)
+
+	// a known miss returns without initializing gopass
+	act.gp = nil
+	termio.Stdin = strings.NewReader(s)
+	require.NoError(t, act.Get(ctx, cmd))
+	assert.Empty(t, stdout.String())
+	assert.Equal(t, 1, inits)
+
+	// storing any credential for the host forgets the miss
+	act.gp = gp
+	termio.Stdin = strings.NewReader("protocol=https\nhost=mirror.example.com\nusername=bob\npassword=s3cret\n")
+	require.NoError(t, act.Store(ctx, cmd))
+	termio.Stdin = strings.NewReader(s)
+	require.NoError(t, act.Get(ctx, cmd))
+	read, err := parseGitCredentials(stdout)
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", read.Password)
+
+	// storing a credential shared with other hosts forgets their misses, too
+	act.cfg = mapConfig{"credential-gopass.missTTL": "1m", "credential-gopass.searchAliases": "true"}
+	sec := secrets.New()
+	sec.SetPassword("old")
+	require.NoError(t, sec.Set("aliases", "api.git.corp"))
+	require.NoError(t, sec.Set("invalid_since", "2026-01-02T00:00:00Z"))
+	require.NoError(t, gp.Set(ctx, "git/git.corp/bob", sec))
+
+	stdout.Reset()
+	s = "protocol=https\nhost=git.corp\nusername=bob\n"
+	termio.Stdin = strings.NewReader(s)
+	require.NoError(t, act.Get(ctx, cmd))
+	assert.Empty(t, stdout.String())
+
+	termio.Stdin = strings.NewReader("protocol=https\nhost=api.git.corp\nusername=bob\npassword=n3w\n")
+	require.NoError(t, act.Store(ctx, cmd))
+	termio.Stdin = strings.NewReader(s)
+	require.NoError(t, act.Get(ctx, cmd))
+	read, err = parseGitCredentials(stdout)
+	require.NoError(t, err)
+	assert.Equal(t, "n3w", read.Password)
+}
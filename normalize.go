@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net"
+	"strings"
+
+	"github.com/gopasspw/gopass/pkg/debug"
+	"golang.org/x/net/idna"
+)
+
+// defaultPorts maps protocols to their default port. A request for the default
+// port uses the same entry as a request without a port.
+var defaultPorts = map[string]string{
+	"ftp":   "21",
+	"ftps":  "990",
+	"git":   "9418",
+	"http":  "80",
+	"https": "443",
+	"imap":  "143",
+	"imaps": "993",
+	"smtp":  "25",
+	"smtps": "465",
+	"ssh":   "22",
+}
+
+// normalize returns a copy of the credentials in a canonical form so that the different
+// spellings of the same repository share one entry, see normalizeHost and normalizePath.
+func normalize(cred *gitCredentials) *gitCredentials {
+	c := *cred
+	c.Host = normalizeHost(cred.Protocol, cred.Host)
+	c.Path = normalizePath(cred.Path)
+
+	return &c
+}
+
+// normalizeHost lowercases the host, converts internationalized domain names to
+// punycode and drops the default port of the protocol, e.g. "Bücher.Example:443"
+// becomes "xn--bcher-kva.example" for https.
+func normalizeHost(protocol, hostport string) string {
+	host, port := splitHostPort(hostport)
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+	if ip == nil {
+		if ascii, err := idna.Lookup.ToASCII(host); err == nil {
+			host = ascii
+		} else {
+			debug.Log("not converting %q to punycode: %s", host, err)
+		}
+	}
+
+	if port == "" || port == defaultPorts[strings.ToLower(protocol)] {
+		if ip != nil && ip.To4() == nil {
+			return "[" + host + "]"
+		}
+
+		return host
+	}
+
+	return net.JoinHostPort(host, port)
+}
+
+// normalizePath drops the slashes around the repository path and a trailing ".git",
+// e.g. "org/repo.git/" becomes "org/repo".
+func normalizePath(path string) string {
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+
+	return strings.TrimSuffix(path, "/")
+}
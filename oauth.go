@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gopasspw/gopass/pkg/debug"
+	"github.com/gopasspw/gopass/pkg/gopass"
+)
+
+// refreshAhead is how long before its expiry an access token is already refreshed.
+const refreshAhead = 5 * time.Minute
+
+// oauthTimeout limits how long git has to wait for the token endpoint.
+const oauthTimeout = 30 * time.Second
+
+// tokenResponse is the successful response of an OAuth2 token endpoint (RFC 6749, section 5.1).
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	Error        string `json:"error"`
+}
+
+// needsRefresh returns true if the secret can be refreshed and is expired or about to expire.
+func needsRefresh(secret gopass.Secret, now time.Time) bool {
+	if rt, _ := secret.Get("oauth_refresh_token"); rt == "" {
+		return false
+	}
+
+	expiry, ok := expiresAt(secret)
+
+	return ok && expiry.Before(now.Add(refreshAhead))
+}
+
+// oauthTokenURL returns the token endpoint configured for the credentials, see
+// credential-gopass.oauthTokenURL. Refresh tokens stored by other tools like
+// git-credential-oauth are left alone if there is none.
+func (s *gc) oauthTokenURL(ctx context.Context, cred *gitCredentials) string {
+	return s.config(ctx, configSection+".oauthTokenURL", cred)
+}
+
+// checkTokenURL returns an error if the refresh token would be sent to the token
+// endpoint unencrypted. Plain http is only accepted for the loopback interface.
+func checkTokenURL(tokenURL string) error {
+	u, err := url.Parse(tokenURL)
+	if err != nil {
+		return fmt.Errorf("invalid token endpoint %q: %w", tokenURL, err)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "https":
+		return nil
+	case "http":
+		if host := u.Hostname(); host == "localhost" || net.ParseIP(host).IsLoopback() {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("refusing to send the refresh token to %q, the token endpoint must use https", tokenURL)
+}
+
+// refresh exchanges the refresh token of the secret for a new access token at the token
+// endpoint configured for the credentials and writes the result back to the store.
+func (s *gc) refresh(ctx context.Context, cred *gitCredentials, path string, secret gopass.Secret) error {
+	tokenURL := s.oauthTokenURL(ctx, cred)
+	if tokenURL == "" {
+		return fmt.Errorf("no %s.oauthTokenURL configured for %s", configSection, credentialURL(cred))
+	}
+	if err := checkTokenURL(tokenURL); err != nil {
+		return err
+	}
+
+	rt, _ := secret.Get("oauth_refresh_token")
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {rt},
+	}
+	if clientID := s.config(ctx, configSection+".oauthClientID", cred); clientID != "" {
+		form.Set("client_id", clientID)
+	}
+	if clientSecret := s.config(ctx, configSection+".oauthClientSecret", cred); clientSecret != "" {
+		form.Set("client_secret", clientSecret)
+	}
+
+	tok, err := requestToken(ctx, tokenURL, form)
+	if err != nil {
+		return err
+	}
+
+	secret.SetPassword(tok.AccessToken)
+	if tok.ExpiresIn > 0 {
+		_ = secret.Set("password_expiry_utc", strconv.FormatInt(time.Now().Unix()+tok.ExpiresIn, 10))
+	} else {
+		_ = secret.Del("password_expiry_utc")
+	}
+	if tok.RefreshToken != "" {
+		_ = secret.Set("oauth_refresh_token", tok.RefreshToken)
+	}
+
+	if err := s.gp.Set(ctx, path, secret); err != nil {
+		return fmt.Errorf("failed to write refreshed token to %q: %w", path, err)
+	}
+	debug.Log("refreshed access token of %q at %s", path, tokenURL)
+
+	return nil
+}
+
+func requestToken(ctx context.Context, tokenURL string, form url.Values) (*tokenResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, oauthTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("invalid token endpoint %q: %w", tokenURL, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to contact token endpoint: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token response: %w", err)
+	}
+
+	tok := &tokenResponse{}
+	if err := json.Unmarshal(body, tok); err != nil {
+		return nil, fmt.Errorf("invalid token response (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || tok.Error != "" {
+		return nil, fmt.Errorf("token endpoint returned HTTP %d: %s", resp.StatusCode, tok.Error)
+	}
+	if tok.AccessToken == "" {
+		return nil, fmt.Errorf("token endpoint returned no access token")
+	}
+
+	return tok, nil
+}
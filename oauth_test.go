@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gopasspw/git-credential-gopass/helpers/githost/githttp"
+	"github.com/gopasspw/gopass/pkg/ctxutil"
+	"github.com/gopasspw/gopass/pkg/gopass/apimock"
+	"github.com/gopasspw/gopass/pkg/gopass/secrets"
+	"github.com/gopasspw/gopass/pkg/termio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitCredentialHelperOAuthRefresh(t *testing.T) { //nolint:paralleltest
+	tokens := githttp.NewTokenHandler("gopass", "refresh-0")
+	srv := httptest.NewServer(tokens)
+	defer srv.Close()
+
+	ctx := t.Context()
+	act := &gc{
+		gp: apimock.New(),
+		cfg: mapConfig{
+			"credential-gopass.oauthTokenURL": srv.URL,
+			"credential-gopass.oauthClientID": "gopass",
+		},
+	}
+
+	stdout := &bytes.Buffer{}
+	Stdout = stdout
+	defer func() {
+		Stdout = os.Stdout
+		termio.Stdin = os.Stdin
+	}()
+
+	cmd := testCmd(t, ctx, nil)
+	ctx = ctxutil.WithStdin(ctx, true)
+
+	sec := secrets.New()
+	sec.SetPassword("stale")
+	require.NoError(t, sec.Set("login", "bob"))
+	require.NoError(t, sec.Set("password_expiry_utc", strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)))
+	require.NoError(t, sec.Set("oauth_refresh_token", "refresh-0"))
+	require.NoError(t, sec.Set("comment", "keep me"))
+	require.NoError(t, act.gp.Set(ctx, "git/example.com/bob", sec))
+
+	s := "protocol=https\nhost=example.com\nusername=bob\n"
+
+	termio.Stdin = strings.NewReader(s)
+	require.NoError(t, act.Get(ctx, cmd))
+	read, err := parseGitCredentials(stdout)
+	require.NoError(t, err)
+	assert.Equal(t, "access-1", read.Password)
+	assert.Equal(t, "refresh-1", read.OAuthRefreshToken)
+	stdout.Reset()
+
+	// the refreshed token was written back
+	stored, err := act.gp.Get(ctx, "git/example.com/bob", "latest")
+	require.NoError(t, err)
+	assert.Equal(t, "access-1", stored.Password())
+	rt, _ := stored.Get("oauth_refresh_token")
+	assert.Equal(t, "refresh-1", rt)
+	comment, _ := stored.Get("comment")
+	assert.Equal(t, "keep me", comment)
+	assert.False(t, isExpired(stored, time.Now()))
+
+	// a fresh token is used as is
+	termio.Stdin = strings.NewReader(s)
+	require.NoError(t, act.Get(ctx, cmd))
+	read, err = parseGitCredentials(stdout)
+	require.NoError(t, err)
+	assert.Equal(t, "access-1", read.Password)
+	assert.Equal(t, 1, tokens.Issued())
+	stdout.Reset()
+
+	// a rejected refresh leaves the expired secret in place
+	require.NoError(t, stored.Set("password_expiry_utc", strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)))
+	require.NoError(t, stored.Set("oauth_refresh_token", "revoked"))
+	require.NoError(t, act.gp.Set(ctx, "git/example.com/bob", stored))
+
+	termio.Stdin = strings.NewReader(s)
+	require.NoError(t, act.Get(ctx, cmd))
+	assert.Empty(t, stdout.String())
+	_, err = act.gp.Get(ctx, "git/example.com/bob", "latest")
+	require.NoError(t, err)
+}
+
+func TestNeedsRefresh(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(10000, 0)
+	sec := secrets.New()
+	assert.False(t, needsRefresh(sec, now))
+
+	require.NoError(t, sec.Set("password_expiry_utc", "10060"))
+	assert.False(t, needsRefresh(sec, now), "no refresh token")
+
+	require.NoError(t, sec.Set("oauth_refresh_token", "xyzzy"))
+	assert.True(t, needsRefresh(sec, now), "about to expire")
+
+	require.NoError(t, sec.Set("password_expiry_utc", "20000"))
+	assert.False(t, needsRefresh(sec, now))
+}
+
+func TestGitCredentialHelperOAuthRefreshChosen(t *testing.T) { //nolint:paralleltest
+	tokens := githttp.NewTokenHandler("gopass", "refresh-0")
+	srv := httptest.NewServer(tokens)
+	defer srv.Close()
+
+	ctx := t.Context()
+	cfg := mapConfig{}
+	act := &gc{
+		gp:  apimock.New(),
+		cfg: cfg,
+	}
+
+	stdout := &bytes.Buffer{}
+	Stdout = stdout
+	defer func() {
+		Stdout = os.Stdout
+		termio.Stdin = os.Stdin
+	}()
+
+	cmd := testCmd(t, ctx, nil)
+	ctx = ctxutil.WithStdin(ctx, true)
+
+	expiry := strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10)
+	for _, user := range []string{"alice", "bob"} {
+		sec := secrets.New()
+		sec.SetPassword("stale-" + user)
+		require.NoError(t, sec.Set("password_expiry_utc", expiry))
+		require.NoError(t, sec.Set("oauth_refresh_token", "refresh-0"))
+		if user == "bob" {
+			require.NoError(t, sec.Set("default", "true"))
+		}
+		require.NoError(t, act.gp.Set(ctx, "git/example.com/"+user, sec))
+	}
+
+	get := func() string {
+		t.Helper()
+
+		stdout.Reset()
+		termio.Stdin = strings.NewReader("protocol=https\nhost=example.com\n")
+		require.NoError(t, act.Get(ctx, cmd))
+		read, err := parseGitCredentials(stdout)
+		require.NoError(t, err)
+
+		return read.Password
+	}
+
+	// without a token endpoint the token is handed out as is
+	assert.Equal(t, "stale-bob", get())
+	assert.Equal(t, 0, tokens.Issued())
+
+	// only the chosen entry is refreshed
+	cfg["credential-gopass.oauthTokenURL"] = srv.URL
+	cfg["credential-gopass.oauthClientID"] = "gopass"
+	assert.Equal(t, "access-1", get())
+	assert.Equal(t, 1, tokens.Issued())
+	alice, err := act.gp.Get(ctx, "git/example.com/alice", "latest")
+	require.NoError(t, err)
+	assert.Equal(t, "stale-alice", alice.Password())
+
+	// the refresh token is not sent over plain http
+	cfg["credential-gopass.oauthTokenURL"] = "http://git.example.com/oauth/token"
+	bob, err := act.gp.Get(ctx, "git/example.com/bob", "latest")
+	require.NoError(t, err)
+	require.NoError(t, bob.Set("password_expiry_utc", strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)))
+	require.NoError(t, act.gp.Set(ctx, "git/example.com/bob", bob))
+	assert.Equal(t, "stale-alice", get())
+	assert.Equal(t, 1, tokens.Issued())
+}
+
+func Test_checkTokenURL(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		url string
+		ok  bool
+	}{
+		{"https://git.example.com/oauth/token", true},
+		{"HTTPS://git.example.com/oauth/token", true},
+		{"http://127.0.0.1:8080/token", true},
+		{"http://[::1]:8080/token", true},
+		{"http://localhost/token", true},
+		{"http://git.example.com/oauth/token", false},
+		{"ftp://git.example.com/token", false},
+		{"git.example.com/token", false},
+	} {
+		err := checkTokenURL(tc.url)
+		if tc.ok {
+			assert.NoError(t, err, tc.url)
+		} else {
+			assert.Error(t, err, tc.url)
+		}
+	}
+}
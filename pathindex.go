@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gopasspw/gopass/pkg/debug"
+	"github.com/urfave/cli/v3"
+)
+
+// pathIndexTTL is how long the path index is trusted before the store is listed again.
+// Secrets added or removed outside of this helper show up after at most this long.
+const pathIndexTTL = time.Hour
+
+// pathIndex holds the sorted names of the secrets in the git trees so that a lookup miss
+// does not have to list every secret in every mount. The names of all secrets are only
+// kept if secrets outside of the git trees are searched as well, see searchURLs.
+type pathIndex struct {
+	Names   []string  `json:"names"`
+	All     bool      `json:"all,omitempty"`
+	Updated time.Time `json:"updated"`
+}
+
+func newPathIndex(ls []string, all bool, now time.Time) *pathIndex {
+	names := make([]string, 0, len(ls))
+	for _, name := range ls {
+		if indexed(name, all) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	return &pathIndex{Names: names, All: all, Updated: now}
+}
+
+// indexed returns true if the name belongs in the index.
+func indexed(name string, all bool) bool {
+	if all {
+		return true
+	}
+	_, _, ok := splitGitTree(name)
+
+	return ok
+}
+
+// loadPathIndex returns the index stored in fn or nil if there is none.
+func loadPathIndex(fn string) *pathIndex {
+	buf, err := os.ReadFile(fn)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			debug.Log("failed to read path index %s: %s", fn, err)
+		}
+
+		return nil
+	}
+
+	idx := &pathIndex{}
+	if err := json.Unmarshal(buf, idx); err != nil {
+		debug.Log("discarding invalid path index %s: %s", fn, err)
+
+		return nil
+	}
+	if !slices.IsSorted(idx.Names) {
+		slices.Sort(idx.Names)
+	}
+
+	return idx
+}
+
+func (i *pathIndex) save(fn string) error {
+	buf, err := json.Marshal(i)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fn), 0o700); err != nil {
+		return err
+	}
+
+	return os.WriteFile(fn, buf, 0o600)
+}
+
+// below returns the names starting with prefix.
+func (i *pathIndex) below(prefix string) []string {
+	start := sort.SearchStrings(i.Names, prefix)
+	end := start
+	for end < len(i.Names) && strings.HasPrefix(i.Names[end], prefix) {
+		end++
+	}
+
+	return i.Names[start:end]
+}
+
+// update adds and removes names from the index.
+func (i *pathIndex) update(added, removed []string) {
+	for _, name := range removed {
+		if n, found := slices.BinarySearch(i.Names, name); found {
+			i.Names = slices.Delete(i.Names, n, n+1)
+		}
+	}
+	for _, name := range added {
+		if !indexed(name, i.All) {
+			continue
+		}
+		if n, found := slices.BinarySearch(i.Names, name); !found {
+			i.Names = slices.Insert(i.Names, n, name)
+		}
+	}
+}
+
+// usePathIndex returns true if the path index is enabled, see credential-gopass.pathIndex.
+func (s *gc) usePathIndex(ctx context.Context, cred *gitCredentials) bool {
+	return strings.EqualFold(s.config(ctx, configSection+".pathIndex", cred), "true")
+}
+
+// lister returns a function listing the secrets below a prefix. It uses the path index
+// if it is enabled and fresh. Otherwise the store is listed at most once per invocation
+// and the index is rebuilt. gopass can only list all secrets in all mounts at once, so
+// without the index every lookup that falls back to listing pays for that.
+func (s *gc) lister(ctx context.Context, cmd *cli.Command, cred *gitCredentials) func(prefix string) ([]string, error) {
+	useIndex := s.usePathIndex(ctx, cred)
+	all := s.searchURLs(ctx, cmd, cred)
+	fn := s.cachePath("path-index.json")
+
+	index := sync.OnceValues(func() (*pathIndex, error) {
+		if useIndex {
+			if idx := loadPathIndex(fn); idx != nil && time.Since(idx.Updated) < pathIndexTTL && (idx.All || !all) {
+				debug.Log("using path index %s", fn)
+
+				return idx, nil
+			}
+		}
+
+		ls, err := s.gp.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		idx := newPathIndex(ls, all, time.Now())
+		if useIndex {
+			if err := idx.save(fn); err != nil {
+				debug.Log("failed to write path index %s: %s", fn, err)
+			}
+		}
+
+		return idx, nil
+	})
+
+	return func(prefix string) ([]string, error) {
+		idx, err := index()
+		if err != nil {
+			return nil, err
+		}
+
+		return idx.below(prefix), nil
+	}
+}
+
+// updatePathIndex keeps the path index in sync with changes made by this helper.
+func (s *gc) updatePathIndex(ctx context.Context, cred *gitCredentials, added, removed []string) {
+	if !s.usePathIndex(ctx, cred) {
+		return
+	}
+
+	fn := s.cachePath("path-index.json")
+	idx := loadPathIndex(fn)
+	if idx == nil {
+		return
+	}
+
+	idx.update(added, removed)
+	if err := idx.save(fn); err != nil {
+		debug.Log("failed to write path index %s: %s", fn, err)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gopasspw/gopass/pkg/ctxutil"
+	"github.com/gopasspw/gopass/pkg/gopass/apimock"
+	"github.com/gopasspw/gopass/pkg/gopass/secrets"
+	"github.com/gopasspw/gopass/pkg/termio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// listingStore counts how often the whole store is listed.
+type listingStore struct {
+	*apimock.MockAPI
+	lists int
+}
+
+func (l *listingStore) List(ctx context.Context) ([]string, error) {
+	l.lists++
+
+	return l.MockAPI.List(ctx)
+}
+
+func TestPathIndex(t *testing.T) {
+	t.Parallel()
+
+	idx := newPathIndex([]string{"git/b.com/bob", "websites/a.com", "git/a.com/alice", "git/a.com/org/bob"}, true, time.Now())
+	assert.Equal(t, []string{"git/a.com/alice", "git/a.com/org/bob"}, idx.below("git/a.com/"))
+	assert.Empty(t, idx.below("git/c.com/"))
+
+	idx.update([]string{"git/a.com/carl", "git/a.com/alice"}, []string{"git/a.com/org/bob", "git/missing"})
+	assert.Equal(t, []string{"git/a.com/alice", "git/a.com/carl", "git/b.com/bob", "websites/a.com"}, idx.Names)
+
+	// only the git trees are indexed unless all secrets are searched
+	idx = newPathIndex([]string{"git/b.com/bob", "websites/a.com", "work/git/a.com/alice", "work/notes"}, false, time.Now())
+	assert.Equal(t, []string{"git/b.com/bob", "work/git/a.com/alice"}, idx.Names)
+	idx.update([]string{"websites/b.com", "git/c.com/carl"}, nil)
+	assert.Equal(t, []string{"git/b.com/bob", "git/c.com/carl", "work/git/a.com/alice"}, idx.Names)
+}
+
+func TestGitCredentialHelperPathIndex(t *testing.T) { //nolint:paralleltest
+	ctx := t.Context()
+	gp := &listingStore{MockAPI: apimock.New()}
+	act := &gc{
+		gp:       gp,
+		cfg:      mapConfig{"credential-gopass.pathIndex": "true"},
+		cacheDir: t.TempDir(),
+	}
+
+	sec := secrets.New()
+	sec.SetPassword("s3cret")
+	require.NoError(t, gp.Set(ctx, "git/example.com/bob", sec))
+	require.NoError(t, gp.Set(ctx, "websites/private.example.com/bob", sec))
+
+	stdout := &bytes.Buffer{}
+	Stdout = stdout
+	defer func() {
+		Stdout = os.Stdout
+		termio.Stdin = os.Stdin
+	}()
+
+	ctx = ctxutil.WithStdin(ctx, true)
+	cmd := testCmd(t, ctx, nil)
+	get := func(host string) string {
+		t.Helper()
+
+		stdout.Reset()
+		termio.Stdin = strings.NewReader("protocol=https\nhost=" + host + "\n")
+		require.NoError(t, act.Get(ctx, cmd))
+		read, err := parseGitCredentials(stdout)
+		require.NoError(t, err)
+
+		return read.Password
+	}
+
+	// the first miss lists the store and builds the index
+	assert.Equal(t, "s3cret", get("example.com"))
+	assert.Equal(t, 1, gp.lists)
+
+	// the index only holds the names the lookup can use
+	buf, err := os.ReadFile(act.cachePath("path-index.json"))
+	require.NoError(t, err)
+	assert.Contains(t, string(buf), "git/example.com/bob")
+	assert.NotContains(t, string(buf), "websites")
+
+	// later lookups use the index
+	assert.Equal(t, "s3cret", get("example.com"))
+	assert.Empty(t, get("example.org"))
+	assert.Equal(t, 1, gp.lists)
+
+	// store and erase keep it up to date
+	termio.Stdin = strings.NewReader("protocol=https\nhost=example.org\nusername=alice\npassword=n3w\n")
+	require.NoError(t, act.Store(ctx, cmd))
+	assert.Equal(t, "n3w", get("example.org"))
+
+	termio.Stdin = strings.NewReader("protocol=https\nhost=example.com\nusername=bob\n")
+	require.NoError(t, act.Erase(ctx, cmd))
+	assert.Empty(t, get("example.com"))
+	assert.Equal(t, 1, gp.lists)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/gopasspw/gopass/pkg/fsutil"
+	"github.com/urfave/cli/v3"
+)
+
+// defaultPathTemplate reproduces the classic [store/]git/<host>[_<port>][/<path>]/<username> layout.
+const defaultPathTemplate = "{{with .Store}}{{.}}/{{end}}git/{{.Host}}{{with .Port}}_{{.}}{{end}}{{with .Path}}/{{.}}{{end}}/{{.User}}"
+
+// pathData is passed to the secret path template. All fields except Store
+// are encoded so they can be used as a single path component, see encodeName.
+type pathData struct {
+	Store    string
+	Protocol string
+	Host     string
+	Port     string
+	Path     string
+	User     string
+}
+
+// splitHostPort splits an optional port off the host git sent. The brackets
+// around IPv6 literals are removed.
+func splitHostPort(hostport string) (string, string) {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		// no port
+		if strings.HasPrefix(hostport, "[") && strings.HasSuffix(hostport, "]") {
+			return hostport[1 : len(hostport)-1], ""
+		}
+
+		return hostport, ""
+	}
+
+	return host, port
+}
+
+func newPathData(store string, cred *gitCredentials) pathData {
+	cred = normalize(cred)
+	host, port := splitHostPort(cred.Host)
+
+	return pathData{
+		Store:    store,
+		Protocol: encodeName(cred.Protocol),
+		Host:     encodeName(host),
+		Port:     encodeName(port),
+		Path:     encodePath(cred.Path),
+		User:     encodeName(cred.Username),
+	}
+}
+
+// legacyPathData cleans the fields like earlier versions did. The cleaning is lossy,
+// e.g. "org/repo" and "org_repo" both became "org_repo". It is only used to find
+// entries that were not migrated yet.
+func legacyPathData(store string, cred *gitCredentials) pathData {
+	host, port := splitHostPort(cred.Host)
+
+	return pathData{
+		Store:    store,
+		Protocol: fsutil.CleanFilename(cred.Protocol),
+		Host:     fsutil.CleanFilename(host),
+		Port:     port,
+		Path:     fsutil.CleanFilename(cred.Path),
+		User:     fsutil.CleanFilename(cred.Username),
+	}
+}
+
+// isPlainByte returns true for the bytes kept as is in a path component.
+func isPlainByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '.' || c == '@' || c == '-'
+}
+
+// encodeName encodes s reversibly as a single path component. Letters, digits and
+// ".@-" are kept and every other byte is escaped as %XX, e.g. "::1" becomes "%3A%3A1".
+// A leading dot is escaped as well since gopass hides such entries. Since "_" is
+// always escaped it can separate the host and the port.
+func encodeName(s string) string {
+	return encodeComponent(s, false)
+}
+
+// encodePath encodes a repository path like encodeName but turns its slashes into "_",
+// e.g. "org/my_repo" becomes "org_my%5Frepo".
+func encodePath(s string) string {
+	return encodeComponent(s, true)
+}
+
+func encodeComponent(s string, path bool) string {
+	var b strings.Builder
+	for i := range len(s) {
+		c := s[i]
+		switch {
+		case path && c == '/':
+			b.WriteByte('_')
+		case isPlainByte(c) && (i > 0 || c != '.'):
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+// decodeName reverses encodeName.
+func decodeName(s string) (string, error) {
+	return decodeComponent(s, false)
+}
+
+// decodePath reverses encodePath.
+func decodePath(s string) (string, error) {
+	return decodeComponent(s, true)
+}
+
+func decodeComponent(s string, path bool) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '%':
+			if i+2 >= len(s) {
+				return "", fmt.Errorf("invalid escape %q in %q", s[i:], s)
+			}
+			v, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+			if err != nil {
+				return "", fmt.Errorf("invalid escape %q in %q", s[i:i+3], s)
+			}
+			b.WriteByte(byte(v))
+			i += 2
+		case path && c == '_':
+			b.WriteByte('/')
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String(), nil
+}
+
+// pathTemplate returns the secret path template. The --path-template flag takes
+// precedence over the credential-gopass.pathTemplate git config.
+func (s *gc) pathTemplate(ctx context.Context, cmd *cli.Command, cred *gitCredentials) string {
+	if tmpl := cmd.String("path-template"); tmpl != "" {
+		return tmpl
+	}
+	if tmpl := s.config(ctx, configSection+".pathTemplate", cred); tmpl != "" {
+		return tmpl
+	}
+
+	return defaultPathTemplate
+}
+
+// renderPath renders the secret path template for the given credentials.
+func renderPath(tmpl, store string, cred *gitCredentials) (string, error) {
+	return render(tmpl, newPathData(store, cred))
+}
+
+// renderLegacyPath renders the secret path template like earlier versions did.
+func renderLegacyPath(tmpl, store string, cred *gitCredentials) (string, error) {
+	return render(tmpl, legacyPathData(store, cred))
+}
+
+func render(tmpl string, data pathData) (string, error) {
+	t, err := template.New("path").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("invalid path template %q: %w", tmpl, err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := t.Execute(buf, data); err != nil {
+		return "", fmt.Errorf("invalid path template %q: %w", tmpl, err)
+	}
+
+	// empty template fields must not leave empty path components behind
+	path := buf.String()
+	for strings.Contains(path, "//") {
+		path = strings.ReplaceAll(path, "//", "/")
+	}
+
+	return strings.TrimPrefix(path, "/"), nil
+}
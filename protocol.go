@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/gopasspw/gopass/pkg/gopass"
+)
+
+// plaintextProtocols maps the protocols using TLS to their plaintext counterparts.
+// A credential stored for the former is not handed out for the latter since the
+// server would receive it unencrypted.
+var plaintextProtocols = map[string]string{
+	"https": "http",
+	"ftps":  "ftp",
+	"imaps": "imap",
+}
+
+// isDowngrade returns true if a credential stored for protocol stored would be sent
+// over the weaker protocol requested.
+func isDowngrade(stored, requested string) bool {
+	weaker, ok := plaintextProtocols[strings.ToLower(stored)]
+
+	return ok && strings.EqualFold(requested, weaker)
+}
+
+// allowDowngrade returns true if credentials may be sent over a weaker protocol than
+// the one they were stored for, see credential-gopass.allowProtocolDowngrade.
+func (s *gc) allowDowngrade(ctx context.Context, cred *gitCredentials) bool {
+	return strings.EqualFold(s.config(ctx, configSection+".allowProtocolDowngrade", cred), "true")
+}
+
+// storedProtocol returns the protocol the secret was stored for: its protocol field or
+// the scheme of its url field.
+func storedProtocol(secret gopass.Secret) string {
+	if protocol, _ := secret.Get("protocol"); protocol != "" {
+		return protocol
+	}
+	if v, _ := secret.Get("url"); v != "" {
+		if u, err := url.Parse(v); err == nil {
+			return u.Scheme
+		}
+	}
+
+	return ""
+}
+
+// refuseDowngrade returns true and warns if the secret at path, stored for protocol
+// stored, must not be sent over the protocol requested.
+func (s *gc) refuseDowngrade(ctx context.Context, cred *gitCredentials, path, stored string) bool {
+	if !isDowngrade(stored, cred.Protocol) || s.allowDowngrade(ctx, cred) {
+		return false
+	}
+
+	fmt.Fprintf(os.Stderr, "gopass warning: refusing to send credential %q stored for %s over %s, see credential-gopass.allowProtocolDowngrade\n",
+		path, stored, cred.Protocol)
+	s.why("%q: rejected, stored for %s but requested over %s", path, stored, cred.Protocol)
+
+	return true
+}
+
+// originProtocol returns the protocol to record for the credentials git sent. A request
+// over a weaker protocol does not weaken the protocol recorded before, otherwise a single
+// plaintext request would lift the protection for all later ones.
+func originProtocol(secret gopass.Secret, cred *gitCredentials) string {
+	stored, _ := secret.Get("protocol")
+	if cred.Protocol == "" || isDowngrade(stored, cred.Protocol) {
+		return stored
+	}
+
+	return cred.Protocol
+}
+
+// originChanged returns true if the protocol or port recorded in the secret
+// differ from the ones git sent. The port of an entry shared with other hosts,
+// e.g. a wildcard or alias entry, is not compared.
+func originChanged(secret gopass.Secret, cred *gitCredentials, shared bool) bool {
+	if protocol, _ := secret.Get("protocol"); protocol != originProtocol(secret, cred) {
+		return true
+	}
+	if shared {
+		return false
+	}
+	_, port := splitHostPort(cred.Host)
+	stored, _ := secret.Get("port")
+
+	return stored != port
+}
+
+// setOrigin records the protocol and port the credential is used with. The port is
+// not recorded for an entry shared with other hosts since they may use different ports.
+func setOrigin(secret gopass.Secret, cred *gitCredentials, shared bool) {
+	if protocol := originProtocol(secret, cred); protocol != "" {
+		_ = secret.Set("protocol", protocol)
+	}
+	if shared {
+		return
+	}
+	if _, port := splitHostPort(cred.Host); port != "" {
+		_ = secret.Set("port", port)
+	} else {
+		_ = secret.Del("port")
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gopasspw/gopass/pkg/ctxutil"
+	"github.com/gopasspw/gopass/pkg/gopass/apimock"
+	"github.com/gopasspw/gopass/pkg/termio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_isDowngrade(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		stored    string
+		requested string
+		want      bool
+	}{
+		{"https", "http", true},
+		{"HTTPS", "http", true},
+		{"imaps", "imap", true},
+		{"https", "https", false},
+		{"http", "https", false},
+		{"https", "ssh", false},
+		{"", "http", false},
+		{"smtp", "smtp", false},
+	} {
+		assert.Equal(t, tc.want, isDowngrade(tc.stored, tc.requested), "%s -> %s", tc.stored, tc.requested)
+	}
+}
+
+func TestGitCredentialHelperProtocolDowngrade(t *testing.T) { //nolint:paralleltest
+	ctx := t.Context()
+	cfg := mapConfig{}
+	act := &gc{
+		gp:       apimock.New(),
+		cfg:      cfg,
+		cacheDir: t.TempDir(),
+	}
+
+	stdout := &bytes.Buffer{}
+	Stdout = stdout
+	defer func() {
+		Stdout = os.Stdout
+		termio.Stdin = os.Stdin
+	}()
+
+	ctx = ctxutil.WithStdin(ctx, true)
+	cmd := testCmd(t, ctx, nil)
+	get := func(protocol string) string {
+		t.Helper()
+
+		stdout.Reset()
+		termio.Stdin = strings.NewReader("protocol=" + protocol + "\nhost=example.com:8080\nusername=bob\n")
+		require.NoError(t, act.Get(ctx, cmd))
+		read, err := parseGitCredentials(stdout)
+		require.NoError(t, err)
+
+		return read.Password
+	}
+
+	// the protocol and port are recorded
+	termio.Stdin = strings.NewReader("protocol=https\nhost=example.com:8080\nusername=bob\npassword=s3cret\n")
+	require.NoError(t, act.Store(ctx, cmd))
+	sec, err := act.gp.Get(ctx, "git/example.com_8080/bob", "latest")
+	require.NoError(t, err)
+	protocol, _ := sec.Get("protocol")
+	assert.Equal(t, "https", protocol)
+	port, _ := sec.Get("port")
+	assert.Equal(t, "8080", port)
+
+	assert.Equal(t, "s3cret", get("https"))
+	assert.Empty(t, get("http"))
+
+	// storing over plain http does not lift the protection
+	termio.Stdin = strings.NewReader("protocol=http\nhost=example.com:8080\nusername=bob\npassword=n3w\n")
+	require.NoError(t, act.Store(ctx, cmd))
+	sec, err = act.gp.Get(ctx, "git/example.com_8080/bob", "latest")
+	require.NoError(t, err)
+	assert.Equal(t, "n3w", sec.Password())
+	protocol, _ = sec.Get("protocol")
+	assert.Equal(t, "https", protocol)
+	assert.Empty(t, get("http"))
+
+	// unless explicitly allowed
+	cfg["credential-gopass.allowProtocolDowngrade"] = "true"
+	assert.Equal(t, "n3w", get("http"))
+}
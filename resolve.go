@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gopasspw/gopass/pkg/ctxutil"
+	"github.com/urfave/cli/v3"
+)
+
+// parseCredentialURL turns an URL into the attributes git would send to the helper.
+// Like git the repository path is only included for http(s) if credential.useHttpPath is set.
+func (s *gc) parseCredentialURL(ctx context.Context, raw string) (*gitCredentials, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("%q is not an absolute URL", raw)
+	}
+
+	cred := &gitCredentials{
+		Protocol: u.Scheme,
+		Host:     u.Host,
+	}
+	if u.User != nil {
+		cred.Username = u.User.Username()
+	}
+
+	path := strings.Trim(u.Path, "/")
+	if u.Scheme != "http" && u.Scheme != "https" {
+		cred.Path = path
+	} else if ok, _ := strconv.ParseBool(s.config(ctx, "credential.useHttpPath", cred)); ok {
+		cred.Path = path
+	}
+
+	return cred, nil
+}
+
+// Resolve explains which secret git would get for an URL. It runs the same lookup
+// as Get but only prints the paths considered, never the secret itself.
+func (s *gc) Resolve(ctx context.Context, cmd *cli.Command) error {
+	if cmd.Args().Len() != 1 {
+		return fmt.Errorf("usage: %s <url>", cmd.FullName())
+	}
+	ctx = ctxutil.WithNoNetwork(ctx, true)
+
+	cred, err := s.parseCredentialURL(ctx, cmd.Args().First())
+	if err != nil {
+		return fmt.Errorf("failed to parse url: %w", err)
+	}
+	fmt.Fprintf(Stdout, "protocol=%s\nhost=%s\n", cred.Protocol, cred.Host)
+	if cred.Path != "" {
+		fmt.Fprintf(Stdout, "path=%s\n", cred.Path)
+	}
+	if cred.Username != "" {
+		fmt.Fprintf(Stdout, "username=%s\n", cred.Username)
+	}
+	fmt.Fprintln(Stdout)
+
+	// work on a copy so the explanation does not leak into other commands
+	r := *s
+	r.explain = func(format string, args ...any) {
+		fmt.Fprintf(Stdout, "  "+format+"\n", args...)
+	}
+
+	m, err := r.find(ctx, cmd, cred)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(Stdout)
+
+	if s.knownMiss(ctx, cmd, cred) {
+		fmt.Fprintln(Stdout, "get: no answer, a recent lookup did not find a credential (see credential-gopass.missTTL)")
+	} else if m == nil {
+		fmt.Fprintln(Stdout, "get: no credential found, git would prompt for one")
+	} else {
+		reply := *cred
+		reply.fill(m.secret)
+		fmt.Fprintf(Stdout, "get: %q from store %s\n", m.path, storeName(m.store))
+		if m.wildcard != "" {
+			fmt.Fprintf(Stdout, "  wildcard: %s\n", m.wildcard)
+		}
+		fmt.Fprintf(Stdout, "  username: %s\n", orDash(reply.Username))
+		if authType, _ := m.secret.Get("authtype"); authType != "" {
+			fmt.Fprintf(Stdout, "  authtype: %s (if git supports it)\n", authType)
+		}
+		if expiry, ok := expiresAt(m.secret); ok {
+			fmt.Fprintf(Stdout, "  expires: %s\n", expiry.UTC().Format(time.RFC3339))
+		}
+	}
+
+	t, err := s.targetPath(ctx, cmd, cred)
+	if err != nil {
+		return err
+	}
+	target := t.path
+	if _, err := s.gp.Get(ctx, target, "latest"); err == nil {
+		fmt.Fprintf(Stdout, "store: %q (exists, updated if the password changed)\n", target)
+		if pattern, ok := wildcardOf(target); ok {
+			fmt.Fprintf(Stdout, "erase: nothing, %q is shared by all hosts matching %s\n", target, pattern)
+		} else if t.own != "" {
+			fmt.Fprintf(Stdout, "erase: nothing, %q is shared with other repositories, the next store goes to %q\n", target, t.own)
+		} else {
+			fmt.Fprintf(Stdout, "erase: %q\n", target)
+		}
+	} else {
+		fmt.Fprintf(Stdout, "store: %q\n", target)
+		fmt.Fprintf(Stdout, "erase: nothing, %q does not exist\n", target)
+	}
+
+	return nil
+}
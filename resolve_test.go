@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/gopasspw/gopass/pkg/gopass/apimock"
+	"github.com/gopasspw/gopass/pkg/gopass/secrets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+func Test_parseCredentialURL(t *testing.T) {
+	t.Parallel()
+
+	ctx := t.Context()
+	act := &gc{cfg: mapConfig{}}
+
+	cred, err := act.parseCredentialURL(ctx, "https://bob@example.com:8443/org/repo.git")
+	require.NoError(t, err)
+	assert.Equal(t, &gitCredentials{Protocol: "https", Host: "example.com:8443", Username: "bob"}, cred)
+
+	act.cfg = mapConfig{"credential.useHttpPath": "true"}
+	cred, err = act.parseCredentialURL(ctx, "https://example.com/org/repo.git/")
+	require.NoError(t, err)
+	assert.Equal(t, &gitCredentials{Protocol: "https", Host: "example.com", Path: "org/repo.git"}, cred)
+
+	_, err = act.parseCredentialURL(ctx, "example.com/org")
+	require.Error(t, err)
+}
+
+func TestResolve(t *testing.T) { //nolint:paralleltest
+	ctx := t.Context()
+	act := &gc{
+		gp:  apimock.New(),
+		cfg: mapConfig{"credential.useHttpPath": "true"},
+	}
+
+	stdout := &bytes.Buffer{}
+	Stdout = stdout
+	defer func() {
+		Stdout = os.Stdout
+	}()
+
+	for path, kvs := range map[string]map[string]string{
+		"git/example.com/org/alice": {"login": "alice@example.com"},
+		"git/example.com/bob":       {"authtype": "Bearer"},
+	} {
+		sec := secrets.New()
+		sec.SetPassword("s3cret")
+		for k, v := range kvs {
+			require.NoError(t, sec.Set(k, v))
+		}
+		require.NoError(t, act.gp.Set(ctx, path, sec))
+	}
+
+	run := func(url string) {
+		t.Helper()
+
+		cmd := &cli.Command{
+			Name: "resolve",
+			Flags: []cli.Flag{
+				&cli.StringFlag{Name: "store"},
+				&cli.StringFlag{Name: "path-template"},
+				&cli.StringFlag{Name: "target-store"},
+				&cli.BoolFlag{Name: "search-urls"},
+			},
+			Action: act.Resolve,
+		}
+		stdout.Reset()
+		require.NoError(t, cmd.Run(ctx, []string{"resolve", url}))
+	}
+
+	run("https://example.com/org/repo")
+	out := stdout.String()
+	assert.NotContains(t, out, "s3cret")
+	assert.Contains(t, out, `"git/example.com/org_repo/": rejected, no such secret`)
+	assert.Contains(t, out, `"git/example.com/org/alice": candidate below "git/example.com/org/"`)
+	assert.Contains(t, out, `"git/example.com/org/alice": accepted, only candidate`)
+	assert.Contains(t, out, `get: "git/example.com/org/alice" from store <root>`)
+	assert.Contains(t, out, "username: alice@example.com")
+	assert.Contains(t, out, `store: "git/example.com/org_repo/"`)
+	assert.Contains(t, out, `erase: nothing, "git/example.com/org_repo/" does not exist`)
+
+	run("https://bob@example.com")
+	out = stdout.String()
+	assert.Contains(t, out, `"git/example.com/bob": accepted, exact match`)
+	assert.Contains(t, out, "authtype: Bearer")
+	assert.Contains(t, out, `store: "git/example.com/bob" (exists, updated if the password changed)`)
+	assert.Contains(t, out, `erase: "git/example.com/bob"`)
+
+	run("https://other.example.com")
+	assert.Contains(t, stdout.String(), "get: no credential found")
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/urfave/cli/v3"
+)
+
+// rootStore is how the root store can be referred to in the --store list.
+const rootStore = "/"
+
+// parseStores splits a comma separated list of store mounts.
+// The root store is given as "/" or as an empty element.
+func parseStores(list string) []string {
+	if list == "" {
+		return []string{""}
+	}
+
+	stores := make([]string, 0, strings.Count(list, ",")+1)
+	for _, store := range strings.Split(list, ",") {
+		store = strings.TrimSpace(store)
+		if store == rootStore {
+			store = ""
+		}
+		stores = append(stores, strings.Trim(store, "/"))
+	}
+
+	return stores
+}
+
+// searchStores returns the stores to search for credentials in priority order.
+func searchStores(cmd *cli.Command) []string {
+	return parseStores(cmd.String("store"))
+}
+
+// targetStore returns the store new credentials are written to. That is the
+// --target-store if given or the first store to search otherwise.
+func targetStore(cmd *cli.Command) string {
+	if cmd.IsSet("target-store") {
+		return parseStores(cmd.String("target-store"))[0]
+	}
+
+	return searchStores(cmd)[0]
+}
+
+// storeName returns a human readable name of the store mount.
+func storeName(store string) string {
+	if store == "" {
+		return "<root>"
+	}
+
+	return store
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gopasspw/gopass/pkg/ctxutil"
+	"github.com/gopasspw/gopass/pkg/gopass/apimock"
+	"github.com/gopasspw/gopass/pkg/gopass/secrets"
+	"github.com/gopasspw/gopass/pkg/termio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseStores(t *testing.T) {
+	t.Parallel()
+
+	for in, want := range map[string][]string{
+		"":                {""},
+		"work":            {"work"},
+		"work,/":          {"work", ""},
+		"work,":           {"work", ""},
+		"ci-team, shared": {"ci-team", "shared"},
+		"/work/,/":        {"work", ""},
+	} {
+		assert.Equal(t, want, parseStores(in), in)
+	}
+}
+
+func TestGitCredentialHelperMultipleStores(t *testing.T) { //nolint:paralleltest
+	ctx := t.Context()
+	act := &gc{
+		gp:  apimock.New(),
+		cfg: mapConfig{},
+	}
+
+	stdout := &bytes.Buffer{}
+	Stdout = stdout
+	defer func() {
+		Stdout = os.Stdout
+		termio.Stdin = os.Stdin
+	}()
+
+	ctx = ctxutil.WithStdin(ctx, true)
+
+	for path, pw := range map[string]string{
+		"work/git/work.example.com/bob": "work",
+		"git/work.example.com/bob":      "root-shadowed",
+		"git/example.org/bob":           "root",
+	} {
+		sec := secrets.New()
+		sec.SetPassword(pw)
+		require.NoError(t, act.gp.Set(ctx, path, sec))
+	}
+
+	cmd := testCmd(t, ctx, map[string]string{"store": "work,/"})
+
+	for host, want := range map[string]string{
+		"work.example.com": "work",
+		"example.org":      "root",
+	} {
+		termio.Stdin = strings.NewReader("protocol=https\nhost=" + host + "\nusername=bob\n")
+		require.NoError(t, act.Get(ctx, cmd))
+		read, err := parseGitCredentials(stdout)
+		require.NoError(t, err)
+		assert.Equal(t, want, read.Password, host)
+		stdout.Reset()
+	}
+
+	// new credentials go to the first store by default
+	termio.Stdin = strings.NewReader("protocol=https\nhost=new.example.com\nusername=bob\npassword=new\n")
+	require.NoError(t, act.Store(ctx, cmd))
+	_, err := act.gp.Get(ctx, "work/git/new.example.com/bob", "latest")
+	require.NoError(t, err)
+
+	// or to the explicit target store
+	cmd = testCmd(t, ctx, map[string]string{"store": "ci-team,shared", "target-store": "shared"})
+	termio.Stdin = strings.NewReader("protocol=https\nhost=ci.example.com\nusername=bot\npassword=ci\n")
+	require.NoError(t, act.Store(ctx, cmd))
+	_, err = act.gp.Get(ctx, "shared/git/ci.example.com/bot", "latest")
+	require.NoError(t, err)
+
+	// erase removes the entry from the store that has it
+	cmd = testCmd(t, ctx, map[string]string{"store": "work,/"})
+	termio.Stdin = strings.NewReader("protocol=https\nhost=example.org\nusername=bob\n")
+	require.NoError(t, act.Erase(ctx, cmd))
+	_, err = act.gp.Get(ctx, "git/example.org/bob", "latest")
+	require.Error(t, err)
+}
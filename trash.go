@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gopasspw/gopass/pkg/debug"
+	"github.com/gopasspw/gopass/pkg/gopass"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	// eraseTrash moves rejected credentials to the trash.
+	eraseTrash = "trash"
+	// eraseMark keeps rejected credentials in place but marks them invalid.
+	eraseMark = "mark"
+	// eraseDelete removes rejected credentials.
+	eraseDelete = "delete"
+)
+
+// defaultTrashPath is the directory below a store mount rejected credentials are moved to.
+// It must not be named like the git tree so that trashed entries are never looked up.
+const defaultTrashPath = "git-trash"
+
+// eraseMode returns how rejected credentials are handled, see credential-gopass.eraseMode.
+func (s *gc) eraseMode(ctx context.Context, cred *gitCredentials) (string, error) {
+	mode := strings.ToLower(s.config(ctx, configSection+".eraseMode", cred))
+	switch mode {
+	case "":
+		return eraseTrash, nil
+	case eraseTrash, eraseMark, eraseDelete:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown %s.eraseMode %q, use %s, %s or %s", configSection, mode, eraseTrash, eraseMark, eraseDelete)
+	}
+}
+
+// eraseThreshold returns how many consecutive rejections are tolerated before a credential is erased.
+func (s *gc) eraseThreshold(ctx context.Context, cred *gitCredentials) int {
+	v := s.config(ctx, configSection+".eraseThreshold", cred)
+	if v == "" {
+		return 1
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		debug.Log("invalid %s.eraseThreshold %q", configSection, v)
+
+		return 1
+	}
+
+	return n
+}
+
+// trashDir returns the configured trash directory.
+func (s *gc) trashDir(ctx context.Context, cred *gitCredentials) string {
+	if dir := strings.Trim(s.config(ctx, configSection+".trashPath", cred), "/"); dir != "" {
+		return dir
+	}
+
+	return defaultTrashPath
+}
+
+// trashPath returns where the secret at path is moved to. Entries in a git tree
+// stay in their store with the git tree replaced by the trash directory.
+func trashPath(dir, path string) string {
+	if store, rest, ok := splitGitTree(path); ok {
+		if store == "" {
+			return dir + "/" + rest
+		}
+
+		return store + "/" + dir + "/" + rest
+	}
+
+	return dir + "/" + path
+}
+
+// rejections counts the consecutive rejections of the credentials per secret path.
+type rejections map[string]int
+
+func loadRejections(fn string) rejections {
+	r := rejections{}
+
+	buf, err := os.ReadFile(fn)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			debug.Log("failed to read rejections %s: %s", fn, err)
+		}
+
+		return r
+	}
+	if err := json.Unmarshal(buf, &r); err != nil {
+		debug.Log("discarding invalid rejections %s: %s", fn, err)
+
+		return rejections{}
+	}
+
+	return r
+}
+
+func (r rejections) save(fn string) error {
+	buf, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fn), 0o700); err != nil {
+		return err
+	}
+
+	return os.WriteFile(fn, buf, 0o600)
+}
+
+// rejected records a rejection of the credential at path and returns the number of consecutive rejections.
+func (s *gc) rejected(path string) int {
+	fn := s.cachePath("rejections.json")
+	r := loadRejections(fn)
+	r[path]++
+	if err := r.save(fn); err != nil {
+		debug.Log("failed to write rejections %s: %s", fn, err)
+	}
+
+	return r[path]
+}
+
+// accepted resets the rejections of the credential at path.
+func (s *gc) accepted(path string) {
+	fn := s.cachePath("rejections.json")
+	r := loadRejections(fn)
+	if _, found := r[path]; !found {
+		return
+	}
+	delete(r, path)
+	if err := r.save(fn); err != nil {
+		debug.Log("failed to write rejections %s: %s", fn, err)
+	}
+}
+
+// isInvalid returns true if the secret was marked as rejected by the server.
+func isInvalid(secret gopass.Secret) bool {
+	v, _ := secret.Get("invalid_since")
+
+	return v != ""
+}
+
+// discard handles a credential git reports as rejected. Depending on the eraseMode
+// the secret is moved to the trash, marked as invalid or deleted. Secrets that no
+// longer hold the rejected password, e.g. because they were rotated meanwhile, are kept.
+func (s *gc) discard(ctx context.Context, cred *gitCredentials, path string) error {
+	mode, err := s.eraseMode(ctx, cred)
+	if err != nil {
+		return err
+	}
+
+	secret, err := s.gp.Get(ctx, path, "latest")
+	if err != nil {
+		debug.Log("not erasing %q: %s", path, err)
+
+		return nil
+	}
+	if rejected := credentialSecret(cred); rejected != "" && rejected != secret.Password() {
+		fmt.Fprintf(os.Stderr, "gopass: keeping %q, it no longer holds the rejected password\n", path)
+
+		return nil
+	}
+
+	if threshold := s.eraseThreshold(ctx, cred); threshold > 1 {
+		if n := s.rejected(path); n < threshold {
+			fmt.Fprintf(os.Stderr, "gopass: keeping rejected credential %q (%d of %d rejections)\n", path, n, threshold)
+
+			return nil
+		}
+		s.accepted(path)
+	}
+
+	if mode == eraseDelete {
+		if err := s.gp.Remove(ctx, path); err != nil {
+			return err
+		}
+		s.updatePathIndex(ctx, cred, nil, []string{path})
+
+		return nil
+	}
+
+	_ = secret.Set("invalid_since", time.Now().UTC().Format(time.RFC3339))
+
+	if mode == eraseMark {
+		fmt.Fprintf(os.Stderr, "gopass: marked rejected credential %q as invalid, use git-credential-gopass restore to undo\n", path)
+
+		return s.gp.Set(ctx, path, secret)
+	}
+
+	trash := trashPath(s.trashDir(ctx, cred), path)
+	if _, err := s.gp.Get(ctx, trash, "latest"); err == nil {
+		// only the latest rejected credential is kept, the older one remains in the history
+		if err := s.gp.Remove(ctx, trash); err != nil {
+			return err
+		}
+	}
+	if err := s.gp.Rename(ctx, path, trash); err != nil {
+		return err
+	}
+	s.updatePathIndex(ctx, cred, []string{trash}, []string{path})
+	_ = secret.Set("trashed_from", path)
+	fmt.Fprintf(os.Stderr, "gopass: moved rejected credential %q to %q, use git-credential-gopass restore to undo\n", path, trash)
+
+	return s.gp.Set(ctx, trash, secret)
+}
+
+// trashed is a rejected credential that can be restored.
+type trashed struct {
+	Path   string
+	Origin string
+	Since  string
+}
+
+// trashedEntries returns the rejected credentials in the trash or marked as invalid.
+func (s *gc) trashedEntries(ctx context.Context) ([]trashed, error) {
+	ls, err := s.gp.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error: %w while listing the storage", err)
+	}
+
+	dir := s.trashDir(ctx, &gitCredentials{})
+	var out []trashed
+	for _, name := range ls {
+		_, _, inGitTree := splitGitTree(name)
+		inTrash := strings.HasPrefix(name, dir+"/") || strings.Contains(name, "/"+dir+"/")
+		if !inGitTree && !inTrash {
+			continue
+		}
+
+		secret, err := s.gp.Get(ctx, name, "latest")
+		if err != nil {
+			debug.Log("failed to read %q: %s", name, err)
+
+			continue
+		}
+		since, _ := secret.Get("invalid_since")
+		origin, _ := secret.Get("trashed_from")
+		switch {
+		case inTrash && origin != "":
+		case inGitTree && since != "":
+			origin = name
+		default:
+			continue
+		}
+
+		out = append(out, trashed{Path: name, Origin: origin, Since: since})
+	}
+
+	return out, nil
+}
+
+// Restore lists the rejected credentials or restores the given ones.
+func (s *gc) Restore(ctx context.Context, cmd *cli.Command) error {
+	entries, err := s.trashedEntries(ctx)
+	if err != nil {
+		return err
+	}
+
+	if cmd.Args().Len() < 1 {
+		if len(entries) < 1 {
+			fmt.Fprintln(Stdout, "No rejected credentials.")
+
+			return nil
+		}
+		for _, e := range entries {
+			fmt.Fprintf(Stdout, "%s (rejected %s)\n", e.Origin, orDash(e.Since))
+		}
+
+		return nil
+	}
+
+	for _, name := range cmd.Args().Slice() {
+		idx := -1
+		for i, e := range entries {
+			if e.Origin == name || e.Path == name {
+				idx = i
+
+				break
+			}
+		}
+		if idx < 0 {
+			return fmt.Errorf("no rejected credential %q", name)
+		}
+		if err := s.restore(ctx, entries[idx]); err != nil {
+			return fmt.Errorf("failed to restore %q: %w", name, err)
+		}
+		fmt.Fprintf(Stdout, "Restored %s\n", entries[idx].Origin)
+	}
+
+	return nil
+}
+
+func (s *gc) restore(ctx context.Context, e trashed) error {
+	if e.Path != e.Origin {
+		if _, err := s.gp.Get(ctx, e.Origin, "latest"); err == nil {
+			return fmt.Errorf("%q already exists", e.Origin)
+		}
+		if err := s.gp.Rename(ctx, e.Path, e.Origin); err != nil {
+			return err
+		}
+		s.updatePathIndex(ctx, &gitCredentials{}, []string{e.Origin}, []string{e.Path})
+	}
+
+	secret, err := s.gp.Get(ctx, e.Origin, "latest")
+	if err != nil {
+		return err
+	}
+	_ = secret.Del("invalid_since")
+	_ = secret.Del("trashed_from")
+	if err := s.gp.Set(ctx, e.Origin, secret); err != nil {
+		return err
+	}
+	s.forgetMisses("")
+
+	return nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gopasspw/gopass/pkg/ctxutil"
+	"github.com/gopasspw/gopass/pkg/gopass/apimock"
+	"github.com/gopasspw/gopass/pkg/gopass/secrets"
+	"github.com/gopasspw/gopass/pkg/termio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+func Test_trashPath(t *testing.T) {
+	t.Parallel()
+
+	for path, want := range map[string]string{
+		"git/example.com/bob":      "git-trash/example.com/bob",
+		"work/git/example.com/bob": "work/git-trash/example.com/bob",
+		"creds/example.com/bob":    "git-trash/creds/example.com/bob",
+	} {
+		assert.Equal(t, want, trashPath(defaultTrashPath, path), path)
+	}
+}
+
+func TestErase(t *testing.T) { //nolint:paralleltest
+	ctx := t.Context()
+
+	stdout := &bytes.Buffer{}
+	Stdout = stdout
+	defer func() {
+		Stdout = os.Stdout
+		termio.Stdin = os.Stdin
+	}()
+
+	ctx = ctxutil.WithStdin(ctx, true)
+	cmd := testCmd(t, ctx, map[string]string{"store": "work"})
+	s := "protocol=https\nhost=example.com\nusername=bob\n"
+
+	setup := func(cfg mapConfig) *gc {
+		t.Helper()
+
+		act := &gc{
+			gp:       apimock.New(),
+			cfg:      cfg,
+			cacheDir: t.TempDir(),
+		}
+		sec := secrets.New()
+		sec.SetPassword("s3cret")
+		require.NoError(t, sec.Set("comment", "valuable notes"))
+		require.NoError(t, act.gp.Set(ctx, "work/git/example.com/bob", sec))
+
+		return act
+	}
+	get := func(act *gc) string {
+		t.Helper()
+
+		stdout.Reset()
+		termio.Stdin = strings.NewReader(s)
+		require.NoError(t, act.Get(ctx, cmd))
+		read, err := parseGitCredentials(stdout)
+		require.NoError(t, err)
+
+		return read.Password
+	}
+	erase := func(act *gc) {
+		t.Helper()
+
+		termio.Stdin = strings.NewReader(s + "password=s3cret\n")
+		require.NoError(t, act.Erase(ctx, cmd))
+	}
+	restore := func(act *gc, args ...string) {
+		t.Helper()
+
+		rcmd := &cli.Command{Name: "restore", Action: act.Restore}
+		stdout.Reset()
+		require.NoError(t, rcmd.Run(ctx, append([]string{"restore"}, args...)))
+	}
+
+	t.Run("trash", func(t *testing.T) {
+		act := setup(mapConfig{})
+		erase(act)
+		assert.Empty(t, get(act))
+
+		trashed, err := act.gp.Get(ctx, "work/git-trash/example.com/bob", "latest")
+		require.NoError(t, err)
+		assert.Equal(t, "s3cret", trashed.Password())
+		comment, _ := trashed.Get("comment")
+		assert.Equal(t, "valuable notes", comment)
+
+		restore(act)
+		assert.Contains(t, stdout.String(), "work/git/example.com/bob (rejected ")
+
+		restore(act, "work/git/example.com/bob")
+		assert.Equal(t, "s3cret", get(act))
+		restored, err := act.gp.Get(ctx, "work/git/example.com/bob", "latest")
+		require.NoError(t, err)
+		assert.NotContains(t, restored.Keys(), "invalid_since")
+		assert.NotContains(t, restored.Keys(), "trashed_from")
+		_, err = act.gp.Get(ctx, "work/git-trash/example.com/bob", "latest")
+		require.Error(t, err)
+	})
+
+	t.Run("mark", func(t *testing.T) {
+		act := setup(mapConfig{"credential-gopass.eraseMode": "mark"})
+		erase(act)
+		assert.Empty(t, get(act))
+
+		marked, err := act.gp.Get(ctx, "work/git/example.com/bob", "latest")
+		require.NoError(t, err)
+		assert.Contains(t, marked.Keys(), "invalid_since")
+
+		// storing a new password clears the mark
+		termio.Stdin = strings.NewReader(s + "password=n3w\n")
+		require.NoError(t, act.Store(ctx, cmd))
+		assert.Equal(t, "n3w", get(act))
+	})
+
+	t.Run("threshold", func(t *testing.T) {
+		act := setup(mapConfig{"credential-gopass.eraseThreshold": "3"})
+		erase(act)
+		erase(act)
+		assert.Equal(t, "s3cret", get(act))
+
+		// a successful use resets the count
+		termio.Stdin = strings.NewReader(s + "password=s3cret\n")
+		require.NoError(t, act.Store(ctx, cmd))
+		erase(act)
+		erase(act)
+		assert.Equal(t, "s3cret", get(act))
+
+		erase(act)
+		assert.Empty(t, get(act))
+	})
+
+	t.Run("rotated", func(t *testing.T) {
+		act := setup(mapConfig{"credential-gopass.eraseMode": "delete"})
+
+		// another process already stored a new password
+		termio.Stdin = strings.NewReader(s + "password=old\n")
+		require.NoError(t, act.Erase(ctx, cmd))
+		assert.Equal(t, "s3cret", get(act))
+
+		// an erase without a password still removes the entry
+		termio.Stdin = strings.NewReader(s)
+		require.NoError(t, act.Erase(ctx, cmd))
+		assert.Empty(t, get(act))
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		act := setup(mapConfig{"credential-gopass.eraseMode": "delete"})
+		erase(act)
+		assert.Empty(t, get(act))
+
+		ls, err := act.gp.List(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, ls)
+	})
+
+	t.Run("parent", func(t *testing.T) {
+		for _, mode := range []string{eraseTrash, eraseMark} {
+			act := setup(mapConfig{"credential-gopass.eraseMode": mode})
+			sec := secrets.New()
+			sec.SetPassword("orgtoken")
+			require.NoError(t, act.gp.Set(ctx, "work/git/example.com/org/bob", sec))
+			private := s + "path=org/private\n"
+
+			// the org entry still serves the other repositories of the org
+			termio.Stdin = strings.NewReader(private + "password=orgtoken\n")
+			require.NoError(t, act.Erase(ctx, cmd))
+			org, err := act.gp.Get(ctx, "work/git/example.com/org/bob", "latest")
+			require.NoError(t, err, mode)
+			assert.Equal(t, "orgtoken", org.Password(), mode)
+			assert.NotContains(t, org.Keys(), "invalid_since", mode)
+
+			// the new token only overrides it for the rejected repository
+			termio.Stdin = strings.NewReader(private + "password=n3w\n")
+			require.NoError(t, act.Store(ctx, cmd))
+			own, err := act.gp.Get(ctx, "work/git/example.com/org_private/bob", "latest")
+			require.NoError(t, err, mode)
+			assert.Equal(t, "n3w", own.Password(), mode)
+			org, err = act.gp.Get(ctx, "work/git/example.com/org/bob", "latest")
+			require.NoError(t, err, mode)
+			assert.Equal(t, "orgtoken", org.Password(), mode)
+
+			// without a rejection the org entry is updated
+			termio.Stdin = strings.NewReader(s + "path=org/public\npassword=rotated\n")
+			require.NoError(t, act.Store(ctx, cmd))
+			org, err = act.gp.Get(ctx, "work/git/example.com/org/bob", "latest")
+			require.NoError(t, err, mode)
+			assert.Equal(t, "rotated", org.Password(), mode)
+		}
+	})
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gopasspw/gopass/pkg/appdir"
+	"github.com/gopasspw/gopass/pkg/debug"
+	"github.com/urfave/cli/v3"
+)
+
+// urlIndexTTL is how long an indexed secret is trusted before it is read again.
+const urlIndexTTL = 24 * time.Hour
+
+// urlIndexEntry holds the url, host and aliases fields of a secret. It never holds the password.
+type urlIndexEntry struct {
+	URL     string    `json:"url,omitempty"`
+	Host    string    `json:"host,omitempty"`
+	Aliases string    `json:"aliases,omitempty"`
+	Checked time.Time `json:"checked"`
+}
+
+// urlIndex maps secret names to their url, host and aliases fields so that a url
+// lookup does not have to decrypt every secret on each invocation.
+type urlIndex struct {
+	Entries map[string]urlIndexEntry `json:"entries"`
+}
+
+// cachePath returns the path of the named file in the cache directory.
+func (s *gc) cachePath(name string) string {
+	dir := s.cacheDir
+	if dir == "" {
+		dir = appdir.New("git-credential-gopass").UserCache()
+	}
+
+	return filepath.Join(dir, name)
+}
+
+func loadURLIndex(fn string) *urlIndex {
+	idx := &urlIndex{Entries: map[string]urlIndexEntry{}}
+
+	buf, err := os.ReadFile(fn)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			debug.Log("failed to read url index %s: %s", fn, err)
+		}
+
+		return idx
+	}
+	if err := json.Unmarshal(buf, idx); err != nil {
+		debug.Log("discarding invalid url index %s: %s", fn, err)
+
+		return &urlIndex{Entries: map[string]urlIndexEntry{}}
+	}
+	if idx.Entries == nil {
+		idx.Entries = map[string]urlIndexEntry{}
+	}
+
+	return idx
+}
+
+func (i *urlIndex) save(fn string) error {
+	buf, err := json.Marshal(i)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fn), 0o700); err != nil {
+		return err
+	}
+
+	return os.WriteFile(fn, buf, 0o600)
+}
+
+// searchURLs returns true if secrets outside of the git tree should be matched by their url and host fields.
+func (s *gc) searchURLs(ctx context.Context, cmd *cli.Command, cred *gitCredentials) bool {
+	if cmd.IsSet("search-urls") {
+		return cmd.Bool("search-urls")
+	}
+
+	return strings.EqualFold(s.config(ctx, configSection+".searchURLs", cred), "true")
+}
+
+// updateURLIndex brings the url index in line with the given list of secrets.
+// Only new secrets and entries older than urlIndexTTL are decrypted.
+func (s *gc) updateURLIndex(ctx context.Context, ls []string) *urlIndex {
+	return s.updateFieldIndex(ctx, s.cachePath("url-index.json"), urlIndexTTL, ls)
+}
+
+// updateFieldIndex brings the index stored in fn in line with the given list of secrets.
+// Only new secrets and entries older than ttl are decrypted.
+func (s *gc) updateFieldIndex(ctx context.Context, fn string, ttl time.Duration, ls []string) *urlIndex {
+	idx := loadURLIndex(fn)
+
+	now := time.Now()
+	exists := make(map[string]bool, len(ls))
+	changed := false
+	for _, name := range ls {
+		exists[name] = true
+		if e, found := idx.Entries[name]; found && now.Sub(e.Checked) < ttl {
+			continue
+		}
+
+		secret, err := s.gp.Get(ctx, name, "latest")
+		if err != nil {
+			debug.Log("failed to index %q: %s", name, err)
+
+			continue
+		}
+		u, _ := secret.Get("url")
+		h, _ := secret.Get("host")
+		a, _ := secret.Get("aliases")
+		idx.Entries[name] = urlIndexEntry{URL: u, Host: h, Aliases: a, Checked: now}
+		changed = true
+	}
+	for name := range idx.Entries {
+		if !exists[name] {
+			delete(idx.Entries, name)
+			changed = true
+		}
+	}
+
+	if changed {
+		if err := idx.save(fn); err != nil {
+			debug.Log("failed to write index %s: %s", fn, err)
+		}
+	}
+
+	return idx
+}
+
+// matches returns true if the url or host field of a secret matches the request.
+// The scheme and the path are only compared if the field contains them. A secret
+// without a scheme is only handed out over plaintext protocols if they are allowed,
+// see findByURL.
+func (e urlIndexEntry) matches(cred *gitCredentials) bool {
+	if e.URL != "" {
+		u, err := url.Parse(e.URL)
+		if err != nil || u.Host == "" {
+			// plain host names are common in the url field, too
+			u, err = url.Parse("//" + e.URL)
+		}
+		if err == nil && u.Host != "" {
+			return (u.Scheme == "" || strings.EqualFold(u.Scheme, cred.Protocol)) &&
+				hostMatches(u.Host, cred.Host) &&
+				pathMatches(u.Path, cred.Path)
+		}
+	}
+
+	return e.Host != "" && hostMatches(e.Host, cred.Host)
+}
+
+// hostMatches compares two hosts. A host without a port matches any port.
+func hostMatches(pattern, host string) bool {
+	if strings.EqualFold(pattern, host) {
+		return true
+	}
+
+	if _, port := splitHostPort(pattern); port != "" {
+		return false
+	}
+	hostname, _ := splitHostPort(host)
+
+	return strings.EqualFold(pattern, hostname)
+}
+
+// pathMatches returns true if path is within the prefix.
+func pathMatches(prefix, path string) bool {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return true
+	}
+	path = strings.Trim(path, "/")
+
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// findByURL looks for secrets anywhere in the store whose url or host field matches the request.
+func (s *gc) findByURL(ctx context.Context, cred *gitCredentials, list func(prefix string) ([]string, error)) (*match, error) {
+	ls, err := list("")
+	if err != nil {
+		return nil, fmt.Errorf("error: %w while listing the storage", err)
+	}
+
+	idx := s.updateURLIndex(ctx, ls)
+
+	var candidates []*match
+	for _, name := range ls {
+		e, found := idx.Entries[name]
+		if !found || !e.matches(cred) {
+			continue
+		}
+
+		secret, err := s.gp.Get(ctx, name, "latest")
+		if err != nil {
+			debug.Log("failed to read %q: %s", name, err)
+
+			continue
+		}
+		m := &match{path: name, secret: secret}
+		if cred.Username != "" && secretUsername(m) != cred.Username {
+			s.why("%q: rejected, url matches but the username %q does not", name, secretUsername(m))
+
+			continue
+		}
+		if storedProtocol(secret) == "" && s.refuseDowngrade(ctx, cred, name, "https") {
+			// a host name without a scheme does not say the secret may be sent in plaintext
+			continue
+		}
+		if !s.usable(ctx, cred, name, secret) {
+			continue
+		}
+		s.why("%q: candidate with a matching url", name)
+
+		candidates = append(candidates, m)
+	}
+
+	if len(candidates) < 1 {
+		return nil, nil
+	}
+
+	m := s.choose(ctx, cred, candidates)
+	if m == nil {
+		reportAmbiguous("with a matching url", candidates)
+	}
+
+	return m, nil
+}
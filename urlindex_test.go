@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gopasspw/gopass/pkg/ctxutil"
+	"github.com/gopasspw/gopass/pkg/gopass"
+	"github.com/gopasspw/gopass/pkg/gopass/apimock"
+	"github.com/gopasspw/gopass/pkg/gopass/secrets"
+	"github.com/gopasspw/gopass/pkg/termio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingStore counts the secrets decrypted.
+type countingStore struct {
+	*apimock.MockAPI
+	gets int
+}
+
+func (c *countingStore) Get(ctx context.Context, name, revision string) (gopass.Secret, error) {
+	c.gets++
+
+	return c.MockAPI.Get(ctx, name, revision)
+}
+
+func TestURLIndexEntryMatches(t *testing.T) {
+	t.Parallel()
+
+	cred := &gitCredentials{Protocol: "https", Host: "github.com", Path: "org/repo.git"}
+	for _, tc := range []struct {
+		entry urlIndexEntry
+		want  bool
+	}{
+		{entry: urlIndexEntry{}, want: false},
+		{entry: urlIndexEntry{URL: "https://github.com"}, want: true},
+		{entry: urlIndexEntry{URL: "https://GitHub.com/"}, want: true},
+		{entry: urlIndexEntry{URL: "github.com"}, want: true},
+		{entry: urlIndexEntry{URL: "http://github.com"}, want: false},
+		{entry: urlIndexEntry{URL: "https://github.com/org"}, want: true},
+		{entry: urlIndexEntry{URL: "https://github.com/org/repo.git"}, want: true},
+		{entry: urlIndexEntry{URL: "https://github.com/other"}, want: false},
+		{entry: urlIndexEntry{URL: "https://github.com/or"}, want: false},
+		{entry: urlIndexEntry{URL: "https://github.com:8443"}, want: false},
+		{entry: urlIndexEntry{URL: "https://gitlab.com"}, want: false},
+		{entry: urlIndexEntry{Host: "github.com"}, want: true},
+		{entry: urlIndexEntry{Host: "gitlab.com"}, want: false},
+	} {
+		assert.Equal(t, tc.want, tc.entry.matches(cred), "%+v", tc.entry)
+	}
+
+	assert.True(t, urlIndexEntry{Host: "example.com"}.matches(&gitCredentials{Host: "example.com:8443"}))
+	assert.False(t, urlIndexEntry{Host: "example.com:443"}.matches(&gitCredentials{Host: "example.com:8443"}))
+}
+
+func TestGitCredentialHelperSearchURLs(t *testing.T) { //nolint:paralleltest
+	ctx := t.Context()
+	gp := &countingStore{MockAPI: apimock.New()}
+	act := &gc{
+		gp:       gp,
+		cfg:      mapConfig{},
+		cacheDir: t.TempDir(),
+	}
+
+	stdout := &bytes.Buffer{}
+	Stdout = stdout
+	defer func() {
+		Stdout = os.Stdout
+		termio.Stdin = os.Stdin
+	}()
+
+	ctx = ctxutil.WithStdin(ctx, true)
+
+	for path, kvs := range map[string][]string{
+		"websites/github.com/alice":   {"url", "github.com"},
+		"websites/gitlab.com/alice":   {"url", "https://gitlab.com/"},
+		"websites/codeberg.org/alice": {"host", "codeberg.org"},
+		"websites/intranet/alice":     {"url", "http://intranet.corp/"},
+		"misc/notes":                  {"comment", "nothing"},
+	} {
+		sec := secrets.New()
+		sec.SetPassword("pw-" + path)
+		require.NoError(t, sec.Set(kvs[0], kvs[1]))
+		require.NoError(t, gp.Set(ctx, path, sec))
+	}
+
+	s := "protocol=https\nhost=github.com\n"
+
+	// opt-in only
+	termio.Stdin = strings.NewReader(s)
+	require.NoError(t, act.Get(ctx, testCmd(t, ctx, nil)))
+	assert.Empty(t, stdout.String())
+
+	cmd := testCmd(t, ctx, map[string]string{"search-urls": "true"})
+	termio.Stdin = strings.NewReader(s)
+	require.NoError(t, act.Get(ctx, cmd))
+	read, err := parseGitCredentials(stdout)
+	require.NoError(t, err)
+	assert.Equal(t, "pw-websites/github.com/alice", read.Password)
+	stdout.Reset()
+
+	// the second lookup is served from the index and only decrypts the match
+	gp.gets = 0
+	termio.Stdin = strings.NewReader(s)
+	require.NoError(t, act.Get(ctx, cmd))
+	read, err = parseGitCredentials(stdout)
+	require.NoError(t, err)
+	assert.Equal(t, "pw-websites/github.com/alice", read.Password)
+	assert.Equal(t, 2, gp.gets, "exact path and the matching secret")
+	stdout.Reset()
+
+	// a different username does not match
+	termio.Stdin = strings.NewReader(s + "username=bob\n")
+	require.NoError(t, act.Get(ctx, cmd))
+	assert.Empty(t, stdout.String())
+	stdout.Reset()
+
+	// entries without a scheme are not sent in plaintext
+	for _, host := range []string{"github.com", "codeberg.org"} {
+		termio.Stdin = strings.NewReader("protocol=http\nhost=" + host + "\n")
+		require.NoError(t, act.Get(ctx, cmd))
+		assert.Empty(t, stdout.String(), host)
+		stdout.Reset()
+	}
+	termio.Stdin = strings.NewReader("protocol=http\nhost=intranet.corp\n")
+	require.NoError(t, act.Get(ctx, cmd))
+	read, err = parseGitCredentials(stdout)
+	require.NoError(t, err)
+	assert.Equal(t, "pw-websites/intranet/alice", read.Password)
+	stdout.Reset()
+
+	// enabled by git config
+	act.cfg = mapConfig{"credential-gopass.searchURLs": "true"}
+	termio.Stdin = strings.NewReader("protocol=https\nhost=gitlab.com\n")
+	require.NoError(t, act.Get(ctx, testCmd(t, ctx, nil)))
+	read, err = parseGitCredentials(stdout)
+	require.NoError(t, err)
+	assert.Equal(t, "pw-websites/gitlab.com/alice", read.Password)
+}
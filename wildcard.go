@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gopasspw/gopass/pkg/debug"
+	"github.com/urfave/cli/v3"
+)
+
+// wildcardPrefix marks a wildcard host in a secret name, e.g. git/*.review.corp/svc.
+const wildcardPrefix = "*."
+
+// isWildcard returns true if host is a wildcard pattern.
+func isWildcard(host string) bool {
+	return strings.HasPrefix(host, wildcardPrefix)
+}
+
+// wildcardOf returns the host pattern if the secret at path is a wildcard entry.
+func wildcardOf(path string) (string, bool) {
+	_, rest, ok := splitGitTree(path)
+	if !ok {
+		return "", false
+	}
+	host := parseName(rest).Host
+
+	return host, isWildcard(host)
+}
+
+// wildcardScore returns how specific the wildcard pattern is for host and false if it
+// does not match. The wildcard stands for one or more labels, so *.corp matches
+// pr-123.review.corp as well. More labels after the wildcard are more specific and a
+// pattern with a port is more specific than one matching any port.
+func wildcardScore(pattern, host string) (int, bool) {
+	if !isWildcard(pattern) {
+		return 0, false
+	}
+
+	pattern, patternPort := splitHostPort(strings.ToLower(pattern))
+	hostname, port := splitHostPort(host)
+	if patternPort != "" && patternPort != port {
+		return 0, false
+	}
+
+	suffix := strings.TrimPrefix(pattern, "*")
+	if !strings.HasSuffix(strings.ToLower(hostname), suffix) || len(hostname) <= len(suffix) {
+		return 0, false
+	}
+
+	score := 2 * strings.Count(suffix, ".")
+	if patternPort != "" {
+		score++
+	}
+
+	return score, true
+}
+
+// wildcardPaths returns how many path segments of the request the path of a wildcard
+// entry covers and false if it does not apply to the requested path.
+func wildcardPaths(entryPath, path string) (int, bool) {
+	if !pathMatches(entryPath, path) {
+		return 0, false
+	}
+	if entryPath == "" {
+		return 0, true
+	}
+
+	return strings.Count(entryPath, "/") + 1, true
+}
+
+// findByWildcard looks for wildcard entries like git/*.review.corp/svc in the git tree of
+// the stores in priority order. Within a store the most specific pattern wins, then the
+// entry with the longest matching path. Unless onlyUsable is set, expired and invalid
+// entries are considered as well so that store and erase can update them. Wildcard
+// entries are only recognized in the default layout, not with a custom path template.
+// It returns nil if no matching secret was found.
+func (s *gc) findByWildcard(ctx context.Context, cmd *cli.Command, cred *gitCredentials, list func(prefix string) ([]string, error), onlyUsable bool) (*match, error) {
+	if tmpl := s.pathTemplate(ctx, cmd, cred); tmpl != defaultPathTemplate {
+		debug.Log("not searching wildcard entries, the path template %q is not the default", tmpl)
+		s.why("wildcard entries are not supported with the path template %q", tmpl)
+
+		return nil, nil
+	}
+
+	req := normalize(cred)
+
+	for _, store := range searchStores(cmd) {
+		prefix := gitTree
+		if store != "" {
+			prefix = store + "/" + gitTree
+		}
+		ls, err := list(prefix + wildcardPrefix)
+		if err != nil {
+			return nil, fmt.Errorf("error: %w while listing the storage", err)
+		}
+
+		var (
+			best      []*match
+			bestScore = -1
+		)
+		for _, name := range ls {
+			c := parseName(strings.TrimPrefix(name, prefix))
+			hostScore, ok := wildcardScore(c.Host, req.Host)
+			if !ok {
+				continue
+			}
+			pathScore, ok := wildcardPaths(c.Path, req.Path)
+			if !ok {
+				s.why("%q: rejected, wildcard %s matches but the path does not", name, c.Host)
+
+				continue
+			}
+
+			secret, err := s.gp.Get(ctx, name, "latest")
+			if err != nil {
+				debug.Log("failed to read %q: %s", name, err)
+				s.why("%q: rejected, failed to read: %s", name, err)
+
+				continue
+			}
+			m := &match{store: store, path: name, secret: secret, wildcard: c.Host}
+			if cred.Username != "" && secretUsername(m) != cred.Username {
+				s.why("%q: rejected, wildcard %s matches but the username %q does not", name, c.Host, secretUsername(m))
+
+				continue
+			}
+			if onlyUsable && !s.usable(ctx, cred, name, secret) {
+				continue
+			}
+
+			// the host counts more than the path
+			score := 1000*hostScore + pathScore
+			s.why("%q: candidate, wildcard %s matches %s", name, c.Host, cred.Host)
+			switch {
+			case score > bestScore:
+				best, bestScore = []*match{m}, score
+			case score == bestScore:
+				best = append(best, m)
+			}
+		}
+
+		if len(best) < 1 {
+			continue
+		}
+		if len(best) > 1 {
+			s.why("%d candidates with the most specific wildcard %s", len(best), best[0].wildcard)
+		}
+		m := s.choose(ctx, cred, best)
+		if m == nil {
+			reportAmbiguous("with a matching wildcard", best)
+
+			return nil, nil
+		}
+		debug.Log("found %q in store %s, wildcard %s", m.path, storeName(store), m.wildcard)
+
+		return m, nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gopasspw/gopass/pkg/ctxutil"
+	"github.com/gopasspw/gopass/pkg/gopass/apimock"
+	"github.com/gopasspw/gopass/pkg/gopass/secrets"
+	"github.com/gopasspw/gopass/pkg/termio"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v3"
+)
+
+func Test_wildcardScore(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		pattern string
+		host    string
+		score   int
+		ok      bool
+	}{
+		{"*.review.corp", "pr-123.review.corp", 4, true},
+		{"*.review.corp", "a.pr-123.review.corp", 4, true},
+		{"*.review.corp", "pr-123.review.corp:8443", 4, true},
+		{"*.review.corp:8443", "pr-123.review.corp:8443", 5, true},
+		{"*.corp", "pr-123.review.corp", 2, true},
+		{"*.Review.Corp", "pr-123.review.corp", 4, true},
+		{"*.review.corp", "review.corp", 0, false},
+		{"*.review.corp", "pr-123.preview.corp", 0, false},
+		{"*.review.corp:8443", "pr-123.review.corp", 0, false},
+		{"review.corp", "pr-123.review.corp", 0, false},
+	} {
+		score, ok := wildcardScore(tc.pattern, tc.host)
+		assert.Equal(t, tc.ok, ok, "%s %s", tc.pattern, tc.host)
+		assert.Equal(t, tc.score, score, "%s %s", tc.pattern, tc.host)
+	}
+}
+
+func Test_wildcardOf(t *testing.T) {
+	t.Parallel()
+
+	pattern, ok := wildcardOf("work/git/*.review.corp_8443/svc")
+	assert.True(t, ok)
+	assert.Equal(t, "*.review.corp:8443", pattern)
+
+	_, ok = wildcardOf("git/review.corp/svc")
+	assert.False(t, ok)
+	_, ok = wildcardOf("websites/*.review.corp/svc")
+	assert.False(t, ok)
+}
+
+func TestGitCredentialHelperWildcard(t *testing.T) { //nolint:paralleltest
+	ctx := t.Context()
+	act := &gc{
+		gp:  apimock.New(),
+		cfg: mapConfig{"credential.useHttpPath": "true"},
+	}
+
+	for path, password := range map[string]string{
+		"git/pr-1.review.corp/svc":              "exact",
+		"git/*.review.corp/svc":                 "review",
+		"git/*.review.corp_8443/svc":            "review-8443",
+		"git/*.review.corp/org_special/svc":     "review-special",
+		"git/*.corp/svc":                        "corp",
+		"git/*.corp/other":                      "other",
+		"git/*.example.com_8443/other.username": "unused",
+	} {
+		sec := secrets.New()
+		sec.SetPassword(password)
+		require.NoError(t, act.gp.Set(ctx, path, sec))
+	}
+
+	stdout := &bytes.Buffer{}
+	Stdout = stdout
+	defer func() {
+		Stdout = os.Stdout
+		termio.Stdin = os.Stdin
+	}()
+
+	ctx = ctxutil.WithStdin(ctx, true)
+	cmd := testCmd(t, ctx, nil)
+	get := func(host, path string) string {
+		t.Helper()
+
+		stdout.Reset()
+		termio.Stdin = strings.NewReader("protocol=https\nhost=" + host + "\npath=" + path + "\nusername=svc\n")
+		require.NoError(t, act.Get(ctx, cmd))
+		read, err := parseGitCredentials(stdout)
+		require.NoError(t, err)
+
+		return read.Password
+	}
+
+	// exact entries take precedence, then the most specific wildcard wins
+	assert.Equal(t, "exact", get("pr-1.review.corp", ""))
+	assert.Equal(t, "review", get("pr-2.review.corp", "org/repo.git"))
+	assert.Equal(t, "review-8443", get("pr-2.review.corp:8443", ""))
+	assert.Equal(t, "review-special", get("pr-2.review.corp", "org/special/repo"))
+	assert.Equal(t, "corp", get("build.corp", ""))
+	assert.Empty(t, get("pr-2.example.com", ""))
+
+	// storing for a matching host updates the wildcard entry
+	termio.Stdin = strings.NewReader("protocol=https\nhost=pr-3.review.corp\nusername=svc\npassword=rotated\n")
+	require.NoError(t, act.Store(ctx, cmd))
+	_, err := act.gp.Get(ctx, "git/pr-3.review.corp/svc", "latest")
+	require.Error(t, err)
+	assert.Equal(t, "rotated", get("pr-2.review.corp", ""))
+
+	// the port of a single host is not recorded in the shared entry
+	termio.Stdin = strings.NewReader("protocol=https\nhost=pr-4.review.corp:9443\nusername=svc\npassword=rotated\n")
+	require.NoError(t, act.Store(ctx, cmd))
+	sec, err := act.gp.Get(ctx, "git/*.review.corp/svc", "latest")
+	require.NoError(t, err)
+	port, _ := sec.Get("port")
+	assert.Empty(t, port)
+
+	// a rejection by a single host does not discard the shared entry
+	termio.Stdin = strings.NewReader("protocol=https\nhost=pr-3.review.corp\nusername=svc\npassword=rotated\n")
+	require.NoError(t, act.Erase(ctx, cmd))
+	assert.Equal(t, "rotated", get("pr-2.review.corp", ""))
+
+	// resolve and list show the wildcard
+	resolve := &cli.Command{
+		Name: "resolve",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "store"},
+			&cli.StringFlag{Name: "path-template"},
+			&cli.StringFlag{Name: "target-store"},
+			&cli.BoolFlag{Name: "search-urls"},
+		},
+		Action: act.Resolve,
+	}
+	stdout.Reset()
+	require.NoError(t, resolve.Run(ctx, []string{"resolve", "https://svc@pr-2.review.corp"}))
+	out := stdout.String()
+	assert.Contains(t, out, `"git/*.review.corp/svc": candidate, wildcard *.review.corp matches pr-2.review.corp`)
+	assert.Contains(t, out, `get: "git/*.review.corp/svc" from store <root>`)
+	assert.Contains(t, out, "wildcard: *.review.corp")
+	assert.Contains(t, out, `erase: nothing, "git/*.review.corp/svc" is shared by all hosts matching *.review.corp`)
+
+	// wildcard entries are only recognized in the default layout
+	cmd = testCmd(t, ctx, map[string]string{"path-template": "git/{{.Host}}/{{.User}}"})
+	stdout.Reset()
+	termio.Stdin = strings.NewReader("protocol=https\nhost=pr-2.review.corp\nusername=svc\n")
+	require.NoError(t, act.Get(ctx, cmd))
+	assert.Empty(t, stdout.String())
+
+	list := &cli.Command{
+		Name: "list",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{Name: "json"},
+			&cli.StringFlag{Name: "host"},
+			&cli.StringFlag{Name: "store"},
+			&cli.StringFlag{Name: "expiry"},
+		},
+		Action: act.List,
+	}
+	stdout.Reset()
+	require.NoError(t, list.Run(ctx, []string{"list", "--host=pr-2.review.corp"}))
+	out = stdout.String()
+	assert.Contains(t, out, "git/*.review.corp/svc")
+	assert.Contains(t, out, "git/*.corp/svc")
+	assert.NotContains(t, out, "git/pr-1.review.corp/svc")
+	assert.NotContains(t, out, "example.com")
+}